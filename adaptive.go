@@ -0,0 +1,61 @@
+package limiter
+
+// Signal is the feedback returned by the probe of an adaptive limiter, see WithAdaptive.
+type Signal uint8
+
+const (
+	// SignalHealthy lets the limit grow by a tenth of the adaptive range.
+	SignalHealthy Signal = iota
+	// SignalHold keeps the limit.
+	SignalHold
+	// SignalOverloaded halves the limit.
+	SignalOverloaded
+)
+
+type adaptive struct {
+	probe    func() Signal
+	min, max Limit
+}
+
+// next returns the limit after the signal, increasing additively and decreasing multiplicatively.
+func (a *adaptive) next(limit Limit, signal Signal) Limit {
+	switch signal {
+	case SignalHealthy:
+		step := (a.max - a.min) / 10
+		if step == 0 {
+			step = 1
+		}
+		limit = addLimit(limit, step)
+	case SignalOverloaded:
+		limit /= 2
+	}
+
+	switch {
+	case limit < a.min:
+		return a.min
+	case limit > a.max:
+		return a.max
+	}
+
+	return limit
+}
+
+// adapt asks the probe for the feedback and adjusts the limit, it runs after a window ends.
+func (l *Limiter) adapt() {
+	signal := l.adaptive.probe()
+
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return
+	}
+
+	now := l.now()
+	l.advanceLocked(now)
+	if limit := l.adaptive.next(l.limit, signal); limit != l.limit {
+		l.setLimitLocked(limit, now)
+	}
+	l.unlock()
+
+	l.wakeCleanup()
+}
@@ -0,0 +1,36 @@
+package limiter
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithAdaptive(t *testing.T) {
+	signals := []Signal{
+		SignalOverloaded, SignalOverloaded, SignalOverloaded, SignalOverloaded, SignalOverloaded, SignalHold,
+	}
+	want := []Limit{50, 25, 12, 10, 10, 10}
+	for i := 0; i < 12; i++ {
+		signals = append(signals, SignalHealthy)
+	}
+	want = append(want, 19, 28, 37, 46, 55, 64, 73, 82, 91, 100, 100, 100)
+
+	clock := newFakeClock()
+	var probes int32
+	l := New(WithRate(1000, time.Minute), WithClock(clock), WithAdaptive(func() Signal {
+		return signals[atomic.AddInt32(&probes, 1)-1]
+	}, 10, 100))
+	defer l.Close()
+
+	if got := l.Limit(); got != 100 {
+		t.Fatalf("limit %d at the start, want the max", got)
+	}
+
+	// The limit stays within the range however long the signal lasts.
+	for i := range signals {
+		clock.Advance(time.Minute)
+		l.Allow()
+		eventually(t, func() bool { return atomic.LoadInt32(&probes) == int32(i+1) && l.Limit() == want[i] })
+	}
+}
@@ -0,0 +1,102 @@
+package limiter
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// AllowAll takes a unit from every limiter or from none of them.
+// The limiters are checked in a fixed order and the units already taken
+// are returned if a later limiter denies. The deny policies are not applied.
+func AllowAll(ls ...*Limiter) bool {
+	return AllowAllN(1, ls...)
+}
+
+// AllowAllN is the same as AllowAll, but takes n units from every limiter.
+func AllowAllN(n Limit, ls ...*Limiter) bool {
+	tokens := make([]Token, 0, len(ls))
+	for _, l := range ordered(ls) {
+		token, ok := l.TryAcquireN(n)
+		if !ok {
+			for i := range tokens {
+				tokens[i].Rollback()
+			}
+
+			return false
+		}
+		tokens = append(tokens, token)
+	}
+
+	for i := range tokens {
+		tokens[i].Commit()
+	}
+
+	return true
+}
+
+// WaitAll waits for a unit from every limiter or takes none of them.
+// The limiters are acquired in a fixed order, so concurrent calls over overlapping
+// limiters can't deadlock. Acquired units are held while waiting for the rest,
+// ctx bounds how long they are held: when it is done, they are returned and WaitAll returns false.
+func WaitAll(ctx context.Context, ls ...*Limiter) bool {
+	return waitAll(ctx, 0, 1, ls)
+}
+
+// WaitAllN is the same as WaitAll, but waits for n units of every limiter.
+func WaitAllN(ctx context.Context, n Limit, ls ...*Limiter) bool {
+	return waitAll(ctx, 0, n, ls)
+}
+
+// WaitAllFor is WaitAll which holds the acquired units at most for hold while waiting for the rest.
+// The hold starts when the first unit is acquired, when it passes the units are returned
+// and WaitAllFor returns false, so a slow limiter doesn't keep the others' units for long.
+// It panics if hold is not positive.
+func WaitAllFor(ctx context.Context, hold time.Duration, ls ...*Limiter) bool {
+	if hold <= 0 {
+		panic("limiter: WaitAllFor hold must be positive")
+	}
+
+	return waitAll(ctx, hold, 1, ls)
+}
+
+// waitAll is WaitAllFor for n units, a zero hold is bounded by ctx only.
+func waitAll(ctx context.Context, hold time.Duration, n Limit, ls []*Limiter) bool {
+	acquired := make([]*Limiter, 0, len(ls))
+	held := ctx
+	for _, l := range ordered(ls) {
+		l.checkNew()
+		if _, err := l.wait(held, "", n); err != nil {
+			for _, l := range acquired {
+				l.refund(n)
+			}
+
+			return false
+		}
+		if len(acquired) == 0 && hold > 0 {
+			var cancel context.CancelFunc
+			held, cancel = context.WithTimeout(ctx, hold)
+			defer cancel()
+		}
+		acquired = append(acquired, l)
+	}
+
+	return true
+}
+
+// ordered returns a copy of ls in the order of creation.
+func ordered(ls []*Limiter) []*Limiter {
+	ls = append([]*Limiter(nil), ls...)
+	sort.Slice(ls, func(i, j int) bool { return ls[i].id < ls[j].id })
+
+	return ls
+}
+
+// refund returns n units taken by wait, they are not counted in Stats and Rate.
+func (l *Limiter) refund(n Limit) {
+	l.mu.Lock()
+	l.releaseLocked(n)
+	l.stats.Allowed -= uint64(n)
+	l.meter.remove(l.now(), uint64(n))
+	l.unlock()
+}
@@ -0,0 +1,120 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAllowAllConcurrent(t *testing.T) {
+	clock := newFakeClock()
+	a := New(WithRate(100, time.Hour), WithClock(clock))
+	b := New(WithRate(100, time.Hour), WithClock(clock))
+	c := New(WithRate(100, time.Hour), WithClock(clock))
+	defer a.Close()
+	defer b.Close()
+	defer c.Close()
+
+	sets := [][]*Limiter{{a, b}, {c, b}, {a, c}, {c, b, a}}
+	granted := make([]int, len(sets))
+	var wg sync.WaitGroup
+	for i := range sets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				var ok bool
+				if j%2 == 0 {
+					ok = AllowAll(sets[i]...)
+				} else {
+					ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+					ok = WaitAll(ctx, sets[i]...)
+					cancel()
+				}
+				if ok {
+					granted[i]++
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Every limiter is used exactly by the calls which got all of their units.
+	for _, l := range []*Limiter{a, b, c} {
+		var want int
+		for i, set := range sets {
+			for _, m := range set {
+				if m == l {
+					want += granted[i]
+				}
+			}
+		}
+		if got := l.Used(); got != Limit(want) {
+			t.Fatalf("used %d, want %d", got, want)
+		}
+		if got := l.Stats().Allowed; got != uint64(want) {
+			t.Fatalf("allowed %d, want %d", got, want)
+		}
+	}
+}
+
+func TestWaitAllForHold(t *testing.T) {
+	clock := newFakeClock()
+	a := New(WithRate(1, time.Hour), WithClock(clock))
+	// The external refill gives no estimate, so the wait isn't failed fast.
+	b := New(WithRate(1, time.Hour), WithClock(clock), WithExternalRefill())
+	defer a.Close()
+	defer b.Close()
+
+	start := time.Now()
+	if WaitAllFor(context.Background(), 20*time.Millisecond, a, b) {
+		t.Fatal("acquired an empty limiter")
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("returned after %v, before the hold passed", elapsed)
+	}
+	if got := a.Stats().Allowed; got != 0 || !a.Allow() {
+		t.Fatalf("the held unit isn't returned, allowed %d", got)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "limiter: WaitAllFor hold must be positive" {
+				t.Fatalf("panicked with %v", r)
+			}
+		}()
+		WaitAllFor(context.Background(), 0, a, b)
+	}()
+}
+
+func TestAllowAllN(t *testing.T) {
+	clock := newFakeClock()
+	a := New(WithRate(10, time.Hour), WithClock(clock))
+	b := New(WithRate(5, time.Hour), WithClock(clock))
+	defer a.Close()
+	defer b.Close()
+
+	if !AllowAllN(4, a, b) {
+		t.Fatal("AllowAllN denied units both limiters have")
+	}
+	// b has a unit only, a keeps its units.
+	if AllowAllN(4, a, b) {
+		t.Fatal("AllowAllN allowed more than b has")
+	}
+	if a.Used() != 4 || b.Used() != 4 {
+		t.Fatalf("used %d and %d, want 4 and 4", a.Used(), b.Used())
+	}
+	if got := b.RetryAfterN(2); got != time.Hour {
+		t.Fatalf("retry after %v for 2 units, want an hour", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if WaitAllN(ctx, 4, a, b) {
+		t.Fatal("WaitAllN succeeded with a canceled context")
+	}
+	if a.Used() != 4 || a.Stats().Allowed != 4 {
+		t.Fatalf("used %d and allowed %d after the canceled wait, want 4 and 4", a.Used(), a.Stats().Allowed)
+	}
+}
@@ -0,0 +1,103 @@
+package limiter
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// The benchmarks of the hot paths. Their names are stable, so runs can be compared:
+//
+//	go test -run '^$' -bench . -benchmem -count 10 ./... > new.txt
+//	benchstat old.txt new.txt
+//
+// BenchmarkKeyedAllow preloads a million keys and needs a few GB of memory,
+// skip it with -bench 'Benchmark[^K]' on a small machine.
+
+// benchLimit is never reached by the benchmarks, so they measure the granted path.
+const benchLimit = 1 << 62
+
+func BenchmarkAllow(b *testing.B) {
+	b.Run("uncontended", func(b *testing.B) {
+		l := New(WithRate(benchLimit, time.Hour))
+		defer l.Close()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.Allow()
+		}
+	})
+
+	b.Run("goroutines=64", func(b *testing.B) {
+		l := New(WithRate(benchLimit, time.Hour))
+		defer l.Close()
+
+		b.SetParallelism((64 + runtime.GOMAXPROCS(0) - 1) / runtime.GOMAXPROCS(0))
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				l.Allow()
+			}
+		})
+	})
+}
+
+func BenchmarkWait(b *testing.B) {
+	b.Run("fast", func(b *testing.B) {
+		l := New(WithRate(benchLimit, time.Hour))
+		defer l.Close()
+		ctx := context.Background()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.Wait(ctx)
+		}
+	})
+
+	// A waiter parks on the empty limiter and is released by AddCapacity every time.
+	b.Run("blocked", func(b *testing.B) {
+		l := New(WithRate(1, time.Hour), WithExternalRefill())
+		defer l.Close()
+		ctx := context.Background()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < b.N; i++ {
+				l.Wait(ctx)
+			}
+		}()
+		for i := 0; i < b.N; i++ {
+			for l.Waiters() == 0 {
+				runtime.Gosched()
+			}
+			l.AddCapacity(1)
+		}
+		<-done
+	})
+}
+
+func BenchmarkKeyedAllow(b *testing.B) {
+	keys := make([]string, 1<<20)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	k := NewKeyed(WithKeyDefaults(WithRate(benchLimit, time.Hour)))
+	defer k.Close()
+	k.Preload(keys)
+
+	b.Run("keys=1M", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			k.Allow(keys[i%len(keys)])
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package limiter
+
+import "time"
+
+// BurnInfo describes a window consumed faster than its interval allows, see WithBurnRateAlert.
+type BurnInfo struct {
+	Used  Limit
+	Limit Limit
+	// Elapsed is the time since the window start.
+	Elapsed time.Duration
+	// Ratio is the consumed part of the limit divided by the elapsed part of the interval.
+	Ratio float64
+	// Exhaustion is when the limit is projected to run out at the current pace.
+	Exhaustion time.Time
+}
+
+type burnAlert struct {
+	threshold float64
+	fn        func(BurnInfo)
+	fired     bool
+}
+
+// checkBurnLocked schedules the burn rate callback if the ratio crossed the threshold
+// for the first time in the window.
+func (l *Limiter) checkBurnLocked(now time.Time) {
+	if l.burn == nil || l.burn.fired || l.log != nil || l.gradualRecovery {
+		return
+	}
+
+	budget, elapsed := l.budgetLocked(), now.Sub(l.windowStart)
+	if budget == 0 || budget == Infinite || elapsed <= 0 {
+		return
+	}
+
+	ratio := float64(l.current) / float64(budget) / (float64(elapsed) / float64(l.interval))
+	if ratio < l.burn.threshold {
+		return
+	}
+
+	l.burn.fired = true
+	fn, info := l.burn.fn, BurnInfo{
+		Used:       l.current,
+		Limit:      budget,
+		Elapsed:    elapsed,
+		Ratio:      ratio,
+		Exhaustion: l.windowStart.Add(time.Duration(float64(l.interval) / ratio)),
+	}
+	l.hooks = append(l.hooks, func() { fn(info) })
+}
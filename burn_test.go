@@ -0,0 +1,43 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithBurnRateAlert(t *testing.T) {
+	clock := newFakeClock()
+	var alerts []BurnInfo
+	l := New(WithRate(100, 10*time.Minute), WithClock(clock), WithBurnRateAlert(2, func(info BurnInfo) {
+		alerts = append(alerts, info)
+	}))
+	defer l.Close()
+
+	// A tenth of the window has passed, the alert fires once when a fifth of the limit is used.
+	clock.Advance(time.Minute)
+	allowed(l, 19)
+	if len(alerts) != 0 {
+		t.Fatalf("alert below the threshold: %+v", alerts)
+	}
+	allowed(l, 30)
+	if len(alerts) != 1 {
+		t.Fatalf("%d alerts in the window, want 1", len(alerts))
+	}
+	want := BurnInfo{Used: 20, Limit: 100, Elapsed: time.Minute, Ratio: 2, Exhaustion: clock.Now().Add(4 * time.Minute)}
+	if alerts[0] != want {
+		t.Fatalf("alert %+v, want %+v", alerts[0], want)
+	}
+
+	// The next window arms it again.
+	clock.Advance(9 * time.Minute)
+	clock.Advance(time.Minute)
+	allowed(l, 10)
+	if len(alerts) != 1 {
+		t.Fatalf("alert in the next window at the pace of the limit: %+v", alerts[1:])
+	}
+	clock.Advance(time.Minute)
+	allowed(l, 40)
+	if len(alerts) != 2 || alerts[1].Used != 40 {
+		t.Fatalf("alerts %+v, want a second one at 40 used", alerts)
+	}
+}
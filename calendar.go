@@ -0,0 +1,55 @@
+package limiter
+
+import "time"
+
+// calendar is a window of whole days starting at the local midnight, see WithKeyAlignment.
+// The days are counted with time.Date, so a day is 23 or 25 hours long when DST changes.
+type calendar struct {
+	days int
+	loc  *time.Location
+}
+
+// withCalendar aligns the windows to days local midnights of loc.
+func withCalendar(days int, loc *time.Location) Option {
+	return func(l *Limiter) {
+		l.calendar = &calendar{days: days, loc: loc}
+	}
+}
+
+// day returns the number of the local day of t since 1970-01-01.
+func (c *calendar) day(t time.Time) int {
+	y, m, d := t.In(c.loc).Date()
+
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / (24 * 60 * 60))
+}
+
+// midnight returns the local midnight of the day number.
+func (c *calendar) midnight(day int) time.Time {
+	return time.Date(1970, 1, 1+day, 0, 0, 0, 0, c.loc)
+}
+
+// window returns the start and the length of the window t is in.
+// The start keeps the monotonic clock reading of t.
+func (c *calendar) window(t time.Time) (time.Time, time.Duration) {
+	first := c.day(t)
+	first -= (first%c.days + c.days) % c.days
+	start, end := c.midnight(first), c.midnight(first+c.days)
+
+	return t.Add(start.Sub(t)), end.Sub(start)
+}
+
+// alignLocked moves an ended window to the window now is in, the following windows
+// may be longer or shorter than the ended one.
+func (l *Limiter) alignLocked(now time.Time) {
+	if now.Sub(l.windowStart) < l.interval {
+		return
+	}
+
+	// The ended window is recorded with its own length, the idle ones after it with the same.
+	l.recordWindowsLocked(now)
+	start, interval := l.calendar.window(now)
+	windows := Limit((l.calendar.day(start) - l.calendar.day(l.windowStart)) / l.calendar.days)
+	l.resetLocked(windows)
+	l.windowStart, l.interval = start, interval
+	l.rolloverLocked(uint64(windows))
+}
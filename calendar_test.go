@@ -0,0 +1,105 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestKeyAlignment(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	midnight := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, ny)
+	}
+
+	clock := &fakeClock{now: time.Date(2024, 3, 9, 12, 0, 0, 0, ny)}
+	k := NewKeyed(WithKeyDefaults(WithRate(2, time.Hour), WithClock(clock)),
+		WithKeyAlignment(func(key string) (time.Duration, *time.Location) {
+			if key == "daily" {
+				return 24 * time.Hour, ny
+			}
+			return 0, nil
+		}))
+	defer k.Close()
+
+	l := k.Get("daily")
+	if at := l.ResetAt(); !at.Equal(midnight(2024, 3, 10)) {
+		t.Fatalf("the first window resets at %v, want the local midnight", at)
+	}
+	if at := k.Get("hourly").ResetAt(); !at.Equal(clock.Now().Add(time.Hour)) {
+		t.Fatalf("a key without alignment resets at %v, want in an hour", at)
+	}
+
+	tests := []struct {
+		name string
+		day  time.Time
+		want time.Duration
+	}{
+		{name: "spring forward", day: midnight(2024, 3, 10), want: 23 * time.Hour},
+		{name: "regular", day: midnight(2024, 3, 11), want: 24 * time.Hour},
+		{name: "fall back", day: midnight(2024, 11, 3), want: 25 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Exhaust the window just after its local midnight.
+			clock.Advance(tt.day.Add(time.Minute).Sub(clock.Now()))
+			id := l.WindowID()
+			k.Allow("daily")
+			k.Allow("daily")
+			if k.Allow("daily") {
+				t.Fatal("allowed above the limit")
+			}
+
+			state := l.State()
+			end := tt.day.AddDate(0, 0, 1)
+			if !state.WindowStart.Equal(tt.day) || !state.NextReset.Equal(end) {
+				t.Fatalf("window from %v to %v, want from %v to %v", state.WindowStart, state.NextReset, tt.day, end)
+			}
+			if got := state.NextReset.Sub(state.WindowStart); got != tt.want {
+				t.Fatalf("window of %v, want %v", got, tt.want)
+			}
+			if got, want := l.RetryAfter(), tt.want-time.Minute; got != want {
+				t.Fatalf("retry after %v, want %v", got, want)
+			}
+
+			// The usage stays until the local midnight.
+			clock.Advance(tt.want - 2*time.Minute)
+			if k.Allow("daily") {
+				t.Fatal("allowed a minute before the local midnight")
+			}
+			clock.Advance(time.Minute)
+			if !k.Allow("daily") {
+				t.Fatal("denied after the local midnight")
+			}
+			if got := l.WindowID(); got <= id {
+				t.Fatalf("window ID %d after the reset, want above %d", got, id)
+			}
+		})
+	}
+}
+
+func TestCalendarWindowID(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Windows of two days passed while idle are counted, the 23 hour day included.
+	clock := &fakeClock{now: time.Date(2024, 3, 9, 12, 0, 0, 0, ny)}
+	l := New(WithRate(1, time.Hour), WithClock(clock), WithLazyReset(), withCalendar(2, ny))
+	defer l.Close()
+
+	start := l.State().WindowStart
+	clock.Advance(time.Date(2024, 3, 19, 12, 0, 0, 0, ny).Sub(clock.Now()))
+	state := l.State()
+	if want := start.In(ny).AddDate(0, 0, 10); !state.WindowStart.Equal(want) || state.WindowID != 5 {
+		t.Fatalf("window %d from %v, want 5 from %v", state.WindowID, state.WindowStart, want)
+	}
+	if got := state.NextReset.Sub(state.WindowStart); got != 48*time.Hour {
+		t.Fatalf("window of %v, want 48h", got)
+	}
+}
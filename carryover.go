@@ -0,0 +1,24 @@
+package limiter
+
+// budgetLocked returns the limit of the current window including the carried units.
+func (l *Limiter) budgetLocked() Limit {
+	return addLimit(l.limitLocked(), l.carried)
+}
+
+// addLimit adds limits, saturating at Infinite.
+func addLimit(a, b Limit) Limit {
+	if a > Infinite-b {
+		return Infinite
+	}
+
+	return a + b
+}
+
+// mulLimit multiplies limits, saturating at Infinite.
+func mulLimit(a, b Limit) Limit {
+	if a != 0 && b > Infinite/a {
+		return Infinite
+	}
+
+	return a * b
+}
@@ -0,0 +1,44 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithCarryOver(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(10, time.Minute), WithClock(clock), WithCarryOver(15))
+	defer l.Close()
+
+	steps := []struct {
+		// budget is the limit of the window before anything is used.
+		budget Limit
+		use    int
+		// windows is the number of windows passed after the use.
+		windows int
+	}{
+		{budget: 10, use: 4, windows: 1},
+		{budget: 16, use: 16, windows: 1},
+		{budget: 10, use: 0, windows: 1},
+		{budget: 20, use: 3, windows: 1},
+		{budget: 25, use: 0, windows: 2},
+		{budget: 25, use: 25, windows: 3},
+		{budget: 25},
+	}
+
+	for i, step := range steps {
+		if got := l.State().Limit; got != step.budget {
+			t.Fatalf("window %d: budget %d, want %d", i, got, step.budget)
+		}
+		if got := l.Stats().Carried; got != step.budget-10 {
+			t.Fatalf("window %d: carried %d, want %d", i, got, step.budget-10)
+		}
+		if got := allowed(l, step.use); got != step.use {
+			t.Fatalf("window %d: allowed %d, want %d", i, got, step.use)
+		}
+		if step.use == int(step.budget) && l.Allow() {
+			t.Fatalf("window %d: allowed above the budget", i)
+		}
+		clock.Advance(time.Duration(step.windows) * time.Minute)
+	}
+}
@@ -0,0 +1,50 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+// Chain is a group of limiters which all have to allow a call,
+// e.g. a per user limit and a global one.
+type Chain struct {
+	ls []*Limiter
+}
+
+// NewChain build and returns new instance Chain.
+// The limiters are kept in the order of creation, see AllowAll.
+func NewChain(ls ...*Limiter) *Chain {
+	for _, l := range ls {
+		l.checkNew()
+	}
+
+	return &Chain{ls: ordered(ls)}
+}
+
+// Allow is AllowAll for the limiters of the chain.
+// If one of them denies, the units taken from the others are returned.
+func (c *Chain) Allow() bool {
+	return AllowAll(c.ls...)
+}
+
+// Wait is WaitAll for the limiters of the chain.
+func (c *Chain) Wait(ctx context.Context) bool {
+	return WaitAll(ctx, c.ls...)
+}
+
+// RetryAfter returns the longest RetryAfter of the limiters.
+func (c *Chain) RetryAfter() time.Duration {
+	var d time.Duration
+	for _, l := range c.ls {
+		if retry := l.RetryAfter(); retry > d {
+			d = retry
+		}
+	}
+
+	return d
+}
+
+// Limiters returns the limiters of the chain.
+func (c *Chain) Limiters() []*Limiter {
+	return append([]*Limiter(nil), c.ls...)
+}
@@ -0,0 +1,56 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestChain(t *testing.T) {
+	clock := newFakeClock()
+	user := New(WithRate(2, time.Minute), WithClock(clock))
+	global := New(WithRate(3, 2*time.Minute), WithClock(clock))
+	defer user.Close()
+	defer global.Close()
+
+	c := NewChain(user, global)
+	if ls := c.Limiters(); len(ls) != 2 {
+		t.Fatalf("%d limiters, want 2", len(ls))
+	}
+
+	// The user limit denies, the unit taken from the global one is returned.
+	if !c.Allow() || !c.Allow() || c.Allow() {
+		t.Fatal("the chain doesn't follow the user limit")
+	}
+	if got := global.Used(); got != 2 {
+		t.Fatalf("global used %d, want 2", got)
+	}
+	if got := c.RetryAfter(); got != time.Minute {
+		t.Fatalf("retry after %v, want a minute of the user limit", got)
+	}
+
+	// Now the global limit is the longest, Wait blocks until both allow.
+	clock.Advance(time.Minute)
+	if !c.Allow() || c.Allow() {
+		t.Fatal("the chain doesn't follow the global limit")
+	}
+	if got := c.RetryAfter(); got != time.Minute {
+		t.Fatalf("retry after %v, want a minute of the global limit", got)
+	}
+	done := make(chan bool)
+	go func() { done <- c.Wait(context.Background()) }()
+	eventually(t, func() bool { return global.Waiters() == 1 || user.Waiters() == 1 })
+	clock.Advance(time.Minute)
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Fatal("Wait failed after both limits reset")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait didn't return after both limits reset")
+	}
+	// The user unit was taken in the window before, while Wait blocked on the global limit.
+	if user.Used() != 0 || global.Used() != 1 {
+		t.Fatalf("used %d and %d after Wait, want 0 and 1", user.Used(), global.Used())
+	}
+}
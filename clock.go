@@ -0,0 +1,91 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of the limiter, see WithClock.
+type Clock interface {
+	Now() time.Time
+	// After waits for d to pass and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is Clock of the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// steadyClock keeps the time of a custom clock from going back. When the clock is stepped back,
+// the time continues from the latest reading instead, so the windows don't stall
+// until the clock catches up. The system clock doesn't need it, time.Now is monotonic.
+type steadyClock struct {
+	Clock
+
+	mu   sync.Mutex
+	last time.Time
+	skew time.Duration
+}
+
+// steady returns c guarded by steadyClock unless it is the system clock.
+func steady(c Clock) Clock {
+	if _, ok := c.(systemClock); ok {
+		return c
+	}
+
+	return &steadyClock{Clock: c}
+}
+
+func (c *steadyClock) Now() time.Time {
+	now := c.Clock.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Before(c.last) {
+		c.skew += c.last.Sub(now)
+	}
+	c.last = now
+
+	return now.Add(c.skew)
+}
+
+// sameClock reports whether a and b are the same clock, guarded or not.
+func sameClock(a, b Clock) bool {
+	if s, ok := a.(*steadyClock); ok {
+		a = s.Clock
+	}
+	if s, ok := b.(*steadyClock); ok {
+		b = s.Clock
+	}
+
+	return a == b
+}
+
+// now returns the current time of the clock.
+func (l *Limiter) now() time.Time {
+	return l.clock.Now()
+}
+
+// after returns a channel which fires after d and a function releasing it early.
+func (l *Limiter) after(d time.Duration) (<-chan time.Time, func()) {
+	return after(l.clock, d)
+}
+
+// after is Limiter.after for the clock c.
+// The system clock uses a timer which can be stopped.
+func after(c Clock, d time.Duration) (<-chan time.Time, func()) {
+	if _, ok := c.(systemClock); ok {
+		timer := time.NewTimer(d)
+		return timer.C, func() { timer.Stop() }
+	}
+
+	return c.After(d), func() {}
+}
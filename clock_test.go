@@ -0,0 +1,189 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock which moves only when the test advances or steps it.
+// Timers follow the time passed with Advance like monotonic timers do, Step moves
+// the reading only.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	elapsed time.Duration
+	timers  []fakeTimer
+	// fired counts the timers fired by Advance.
+	fired int
+}
+
+type fakeTimer struct {
+	at time.Duration
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.timers = append(c.timers, fakeTimer{at: c.elapsed + d, c: ch})
+
+	return ch
+}
+
+// Advance moves the clock by d and fires the timers due by then.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	c.elapsed += d
+	timers := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at > c.elapsed {
+			timers = append(timers, t)
+			continue
+		}
+		t.c <- c.now
+		c.fired++
+	}
+	c.timers = timers
+}
+
+// wakeups returns how many timers fired and how many are pending.
+func (c *fakeClock) wakeups() (fired, pending int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.fired, len(c.timers)
+}
+
+// Step moves the clock reading by d without firing the timers, like a wall clock step.
+func (c *fakeClock) Step(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// eventually fails the test if cond doesn't become true within a second.
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition is not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// allowed returns how many of n Allow calls succeed.
+func allowed(l *Limiter, n int) int {
+	ok := 0
+	for i := 0; i < n; i++ {
+		if l.Allow() {
+			ok++
+		}
+	}
+
+	return ok
+}
+
+func TestWithClockDeadline(t *testing.T) {
+	clock := newFakeClock()
+	// The fake time is far from the system time, the deadline must be compared as a duration.
+	clock.Advance(-24 * time.Hour * 365)
+	l := New(WithRate(1, time.Hour), WithClock(clock))
+	defer l.Close()
+
+	l.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := l.WaitErr(ctx); !errors.Is(err, ErrWouldExceedDeadline) {
+		t.Fatalf("got %v, want ErrWouldExceedDeadline", err)
+	}
+}
+
+func TestClockStepBack(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(2, time.Minute), WithClock(clock))
+	defer l.Close()
+
+	allowed(l, 2)
+	done := make(chan error)
+	go func() { done <- l.WaitErr(context.Background()) }()
+	eventually(t, func() bool { return l.Waiters() == 1 })
+
+	// The window ends a minute after it started, not when the clock catches up.
+	clock.Advance(30 * time.Second)
+	if l.Allow() {
+		t.Fatal("allowed in the middle of the window")
+	}
+	clock.Step(-10 * time.Minute)
+	if l.Allow() {
+		t.Fatal("allowed after the clock stepped back")
+	}
+	clock.Advance(30 * time.Second)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("wait got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the window stalled after the clock stepped back")
+	}
+	if got := allowed(l, 2); got != 1 {
+		t.Fatalf("allowed %d in the next window, want 1", got)
+	}
+	if got := l.WindowID(); got != 1 {
+		t.Fatalf("window %d, want 1", got)
+	}
+}
+
+func TestClockJumpForward(t *testing.T) {
+	clock := newFakeClock()
+	resets := make(chan struct{}, 200)
+	l := New(WithRate(2, time.Minute), WithClock(clock), WithOnReset(func() { resets <- struct{}{} }))
+	defer l.Close()
+
+	allowed(l, 2)
+	clock.Advance(2 * time.Hour)
+
+	// The passed windows are counted, but only the limit of one window is granted.
+	if got := allowed(l, 5); got != 2 {
+		t.Fatalf("allowed %d after the jump, want 2", got)
+	}
+	if got := l.WindowID(); got != 120 {
+		t.Fatalf("window %d after the jump, want 120", got)
+	}
+	eventually(t, func() bool { return len(resets) > 0 })
+	time.Sleep(10 * time.Millisecond)
+	if got := len(resets); got != 1 {
+		t.Fatalf("%d resets after the jump, want 1", got)
+	}
+	if want := clock.Now().Add(time.Minute); !l.ResetAt().Equal(want) {
+		t.Fatalf("reset at %v, want %v", l.ResetAt(), want)
+	}
+}
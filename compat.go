@@ -0,0 +1,91 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Interface is the part of the golang.org/x/time/rate.Limiter API which doesn't depend
+// on its types, both *rate.Limiter and *RateAdapter satisfy it.
+type Interface interface {
+	Allow() bool
+	AllowN(t time.Time, n int) bool
+	Wait(ctx context.Context) error
+	WaitN(ctx context.Context, n int) error
+	Burst() int
+}
+
+// RateAdapter exposes a Limiter with the method set of golang.org/x/time/rate.Limiter,
+// so it can replace it in code written against that package.
+// Reserve returns *Reservation which has OK, Delay and Cancel like rate.Reservation.
+type RateAdapter struct {
+	l *Limiter
+}
+
+// NewRateAdapter build and returns new instance RateAdapter.
+func NewRateAdapter(l *Limiter) *RateAdapter {
+	l.checkNew()
+	return &RateAdapter{l: l}
+}
+
+// Limiter returns the adapted limiter.
+func (a *RateAdapter) Limiter() *Limiter {
+	return a.l
+}
+
+// Allow is Limiter.Allow.
+func (a *RateAdapter) Allow() bool {
+	return a.l.Allow()
+}
+
+// AllowN is Limiter.AllowN. The decision is made at the time of the limiter clock,
+// t is accepted for compatibility only.
+func (a *RateAdapter) AllowN(_ time.Time, n int) bool {
+	if n <= 0 {
+		return true
+	}
+
+	return a.l.AllowN(Limit(n))
+}
+
+// Wait is Limiter.WaitErr.
+func (a *RateAdapter) Wait(ctx context.Context) error {
+	return a.l.WaitErr(ctx)
+}
+
+// WaitN is the same as Wait for n units, it fails at once
+// if n units can never be available.
+func (a *RateAdapter) WaitN(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+
+	a.l.checkStrictWait(ctx, "WaitN")
+	_, err := a.l.wait(ctx, "", Limit(n))
+	return err
+}
+
+// Reserve is Limiter.Reserve.
+func (a *RateAdapter) Reserve() *Reservation {
+	return a.l.Reserve()
+}
+
+// ReserveN is Limiter.ReserveN. Like AllowN, t is accepted for compatibility only.
+func (a *RateAdapter) ReserveN(_ time.Time, n int) *Reservation {
+	if n < 0 {
+		n = 0
+	}
+
+	return a.l.ReserveN(Limit(n))
+}
+
+// Burst returns the limit, math.MaxInt if it doesn't fit in int.
+func (a *RateAdapter) Burst() int {
+	limit := a.l.Limit()
+	if limit > math.MaxInt {
+		return math.MaxInt
+	}
+
+	return int(limit)
+}
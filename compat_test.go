@@ -0,0 +1,55 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+var _ Interface = (*RateAdapter)(nil)
+
+func TestRateAdapter(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(3, time.Minute), WithClock(clock))
+	defer l.Close()
+
+	a := NewRateAdapter(l)
+	if a.Limiter() != l || a.Burst() != 3 {
+		t.Fatalf("burst %d, want the limit", a.Burst())
+	}
+
+	// The time passed to AllowN is ignored, no units always fit.
+	if !a.Allow() || !a.AllowN(clock.Now().Add(time.Hour), 0) || a.AllowN(time.Time{}, 3) || !a.AllowN(time.Time{}, 2) {
+		t.Fatal("AllowN doesn't follow the limiter")
+	}
+
+	ctx := context.Background()
+	if err := a.WaitN(ctx, 4); err == nil {
+		t.Fatal("WaitN succeeded for more units than the limit")
+	}
+	if err := a.WaitN(ctx, 0); err != nil {
+		t.Fatalf("unexpected error for no units: %v", err)
+	}
+
+	r := a.ReserveN(time.Time{}, 1)
+	if !r.OK() || r.Delay() != time.Minute {
+		t.Fatalf("reservation ok %t with delay %v, want the next window", r.OK(), r.Delay())
+	}
+	r.Cancel()
+
+	done := make(chan error)
+	go func() { done <- a.Wait(ctx) }()
+	eventually(t, func() bool { return l.Waiters() == 1 })
+	clock.Advance(time.Minute)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := l.Used(); got != 1 {
+		t.Fatalf("used %d, want the canceled reservation returned", got)
+	}
+
+	if got := NewRateAdapter(New(WithLazyReset())).Burst(); got != math.MaxInt {
+		t.Fatalf("burst %d of an infinite limit, want math.MaxInt", got)
+	}
+}
@@ -0,0 +1,65 @@
+package limiter
+
+import "context"
+
+// Concurrency limits the number of calls in flight, unlike Limiter it doesn't
+// depend on time. Every successful Acquire or TryAcquire must be followed by Release.
+type Concurrency struct {
+	slots chan struct{}
+}
+
+// NewConcurrency build and returns new instance Concurrency
+// which lets maxInFlight calls in at once. It panics if maxInFlight is not positive.
+func NewConcurrency(maxInFlight int) *Concurrency {
+	if maxInFlight <= 0 {
+		panic("limiter: maxInFlight must be positive")
+	}
+
+	return &Concurrency{slots: make(chan struct{}, maxInFlight)}
+}
+
+// Acquire waits for a free slot and returns ctx error if ctx is done first.
+func (c *Concurrency) Acquire(ctx context.Context) error {
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TryAcquire takes a free slot if there is one.
+func (c *Concurrency) TryAcquire() bool {
+	select {
+	case c.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release frees the slot taken by Acquire or TryAcquire.
+// It panics if no slot is taken.
+func (c *Concurrency) Release() {
+	select {
+	case <-c.slots:
+	default:
+		panic("limiter: Release without Acquire")
+	}
+}
+
+// InFlight returns the number of taken slots.
+func (c *Concurrency) InFlight() int {
+	return len(c.slots)
+}
+
+// Max returns the maximum number of calls in flight.
+func (c *Concurrency) Max() int {
+	return cap(c.slots)
+}
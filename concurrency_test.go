@@ -0,0 +1,78 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrency(t *testing.T) {
+	const max = 3
+	c := NewConcurrency(max)
+
+	var (
+		inFlight, peak int32
+		wg             sync.WaitGroup
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Acquire(context.Background()); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			defer c.Release()
+
+			n := atomic.AddInt32(&inFlight, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+		}()
+	}
+	wg.Wait()
+
+	if peak > max {
+		t.Fatalf("%d calls in flight, want at most %d", peak, max)
+	}
+	if got := c.InFlight(); got != 0 {
+		t.Fatalf("%d slots taken after every Release", got)
+	}
+}
+
+func TestConcurrencyAcquire(t *testing.T) {
+	c := NewConcurrency(1)
+	if c.Max() != 1 || !c.TryAcquire() || c.TryAcquire() {
+		t.Fatal("TryAcquire doesn't follow the max")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the context error", err)
+	}
+
+	// A failed Acquire takes no slot, the waiting one gets the released slot.
+	done := make(chan error)
+	go func() { done <- c.Acquire(context.Background()) }()
+	c.Release()
+	if err := <-done; err != nil || c.InFlight() != 1 {
+		t.Fatalf("got %v with %d in flight, want the released slot", err, c.InFlight())
+	}
+	c.Release()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Release without Acquire didn't panic")
+		}
+	}()
+	c.Release()
+}
@@ -0,0 +1,55 @@
+package limiter
+
+import "context"
+
+// DenyMode defines what Throttle does when there is no unit for a call.
+type DenyMode uint8
+
+const (
+	// DenyBlock waits for a unit like Wait.
+	DenyBlock DenyMode = iota
+	// DenyDrop skips the call and returns the zero result without an error.
+	DenyDrop
+	// DenyError skips the call and returns *RateLimitError.
+	DenyError
+)
+
+// Do waits for a unit and calls fn. If the wait fails, fn is not called
+// and the error of WaitErr is returned, otherwise the error of fn.
+func (l *Limiter) Do(ctx context.Context, fn func() error) error {
+	if err := l.WaitErr(ctx); err != nil {
+		return err
+	}
+
+	return fn()
+}
+
+// Throttle returns fn wrapped with the limiter, every call takes a unit.
+// The mode defines what happens with a call when there is no unit; with DenyBlock
+// the error of a failed wait is returned, see WaitErr. It is safe for concurrent use.
+func Throttle[T, R any](l *Limiter, mode DenyMode, fn func(T) R) func(context.Context, T) (R, error) {
+	l.checkNew()
+
+	return func(ctx context.Context, arg T) (R, error) {
+		var (
+			zero R
+			err  error
+		)
+		switch mode {
+		case DenyDrop:
+			if !l.Allow() {
+				return zero, nil
+			}
+		case DenyError:
+			err = l.AllowErr()
+		default:
+			err = l.WaitErr(ctx)
+		}
+
+		if err != nil {
+			return zero, err
+		}
+
+		return fn(arg), nil
+	}
+}
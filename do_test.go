@@ -0,0 +1,86 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	l := New(WithRate(1, time.Hour))
+	defer l.Close()
+
+	errFn := errors.New("fn")
+	calls := 0
+	fn := func() error {
+		calls++
+		return errFn
+	}
+	if err := l.Do(context.Background(), fn); !errors.Is(err, errFn) {
+		t.Fatalf("got %v, want the error of fn", err)
+	}
+
+	// The wait fails, fn isn't called.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := l.Do(ctx, fn); !errors.Is(err, ErrWouldExceedDeadline) {
+		t.Fatalf("got %v, want ErrWouldExceedDeadline", err)
+	}
+	if calls != 1 {
+		t.Fatalf("%d calls, want 1", calls)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	double := func(n int) int { return 2 * n }
+
+	tests := []struct {
+		name    string
+		mode    DenyMode
+		wantErr bool
+	}{
+		{name: "drop", mode: DenyDrop},
+		{name: "error", mode: DenyError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(WithRate(1, time.Hour))
+			defer l.Close()
+
+			fn := Throttle(l, tt.mode, double)
+			if got, err := fn(context.Background(), 2); got != 4 || err != nil {
+				t.Fatalf("got %d and %v, want 4", got, err)
+			}
+
+			// The call over the limit is skipped with the zero result.
+			got, err := fn(context.Background(), 3)
+			if got != 0 {
+				t.Fatalf("got %d over the limit, want the zero result", got)
+			}
+			if errors.As(err, new(*RateLimitError)) != tt.wantErr {
+				t.Fatalf("got %v over the limit", err)
+			}
+		})
+	}
+
+	t.Run("block", func(t *testing.T) {
+		clock := newFakeClock()
+		l := New(WithRate(1, time.Minute), WithClock(clock))
+		defer l.Close()
+
+		fn := Throttle(l, DenyBlock, double)
+		fn(context.Background(), 1)
+		done := make(chan int)
+		go func() {
+			got, _ := fn(context.Background(), 5)
+			done <- got
+		}()
+		eventually(t, func() bool { return l.Waiters() == 1 })
+		clock.Advance(time.Minute)
+		if got := <-done; got != 10 {
+			t.Fatalf("got %d after the wait, want 10", got)
+		}
+	})
+}
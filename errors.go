@@ -0,0 +1,63 @@
+package limiter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrClosed is returned when the limiter is closed.
+	ErrClosed = errors.New("limiter: closed")
+	// ErrQueueFull is returned when too many goroutines already wait, see WithMaxWaiters.
+	ErrQueueFull = errors.New("limiter: wait queue is full")
+	// ErrWouldExceedDeadline is returned when the context deadline comes
+	// before a unit can be available.
+	ErrWouldExceedDeadline = errors.New("limiter: wait would exceed context deadline")
+	// ErrExceedsLimit is returned when the requested units can never be available.
+	ErrExceedsLimit = errors.New("limiter: request exceeds the limit")
+	// ErrFiltered is returned when a filter denied the call, see WithFilter.
+	ErrFiltered = errors.New("limiter: denied by filter")
+)
+
+// RateLimitError is returned when a call exceeded the limit.
+// Use errors.As to get it from wrapped errors.
+type RateLimitError struct {
+	// Name is the limiter name, see WithName.
+	Name       string
+	Limit      Limit
+	Used       Limit
+	RetryAfter time.Duration
+}
+
+// Error implements error.
+func (e *RateLimitError) Error() string {
+	name := "limiter"
+	if e.Name != "" {
+		name += " " + strconv.Quote(e.Name)
+	}
+
+	if e.RetryAfter == Never {
+		return fmt.Sprintf("%s: rate limit exceeded", name)
+	}
+
+	return fmt.Sprintf("%s: rate limit exceeded, retry after %s", name, e.RetryAfter)
+}
+
+// SetHeaders sets Retry-After, X-RateLimit-Limit and X-RateLimit-Remaining headers.
+// Retry-After is rounded up to whole seconds and omitted if the retry time is unknown.
+func (e *RateLimitError) SetHeaders(h http.Header) {
+	if e.RetryAfter != Never {
+		seconds := (e.RetryAfter + time.Second - 1) / time.Second
+		h.Set("Retry-After", strconv.FormatInt(int64(seconds), 10))
+	}
+
+	var remaining Limit
+	if e.Used < e.Limit {
+		remaining = e.Limit - e.Used
+	}
+	h.Set("X-RateLimit-Limit", strconv.FormatUint(uint64(e.Limit), 10))
+	h.Set("X-RateLimit-Remaining", strconv.FormatUint(uint64(remaining), 10))
+}
@@ -0,0 +1,52 @@
+package limiter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitError(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(2, time.Minute), WithClock(clock), WithName("api"))
+	defer l.Close()
+
+	allowed(l, 2)
+	clock.Advance(20 * time.Second)
+	err := fmt.Errorf("call: %w", l.AllowErr())
+
+	var rle *RateLimitError
+	if !errors.As(err, &rle) {
+		t.Fatalf("got %v, want a wrapped RateLimitError", err)
+	}
+	want := RateLimitError{Name: "api", Limit: 2, Used: 2, RetryAfter: 40 * time.Second}
+	if *rle != want {
+		t.Fatalf("got %+v, want %+v", *rle, want)
+	}
+	if got := err.Error(); got != `call: limiter "api": rate limit exceeded, retry after 40s` {
+		t.Fatalf("message %q", got)
+	}
+
+	h := http.Header{}
+	rle.SetHeaders(h)
+	if h.Get("Retry-After") != "40" || h.Get("X-RateLimit-Limit") != "2" || h.Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("headers %v", h)
+	}
+
+	// The retry time is unknown, the message and the headers omit it.
+	never := &RateLimitError{Limit: 2, Used: 2, RetryAfter: Never}
+	if got := never.Error(); got != "limiter: rate limit exceeded" {
+		t.Fatalf("message %q", got)
+	}
+	h = http.Header{}
+	never.SetHeaders(h)
+	if _, ok := h["Retry-After"]; ok {
+		t.Fatalf("Retry-After is set for an unknown retry time: %v", h)
+	}
+
+	if errors.As(fmt.Errorf("call: %w", ErrClosed), new(*RateLimitError)) {
+		t.Fatal("ErrClosed is a RateLimitError")
+	}
+}
@@ -0,0 +1,97 @@
+package example
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// LoadResult counts the responses of Load.
+type LoadResult struct {
+	Allowed int
+	Denied  int
+	// Other is the number of responses with another status.
+	Other int
+	// BadHeaders is the number of responses without valid rate limit headers.
+	BadHeaders int
+}
+
+// Load sends requests to url from workers goroutines, the keys of the clients
+// are taken in turn. It returns the first error of the client.
+func Load(ctx context.Context, client *http.Client, url string, requests, workers int, keys []string) (LoadResult, error) {
+	var (
+		mu     sync.Mutex
+		result LoadResult
+		first  error
+		wg     sync.WaitGroup
+	)
+	next := make(chan int)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range next {
+				allowed, denied, valid, err := send(ctx, client, url, keys[n%len(keys)])
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					if first == nil {
+						first = err
+					}
+				case allowed:
+					result.Allowed++
+				case denied:
+					result.Denied++
+				default:
+					result.Other++
+				}
+				if err == nil && !valid {
+					result.BadHeaders++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for n := 0; n < requests; n++ {
+		next <- n
+	}
+	close(next)
+	wg.Wait()
+
+	return result, first
+}
+
+// send sends a request with the key and checks the headers of the response.
+func send(ctx context.Context, client *http.Client, url, key string) (allowed, denied, valid bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, false, false, err
+	}
+	req.Header.Set(KeyHeader, key)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, false, false, err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return false, false, false, err
+	}
+
+	allowed = resp.StatusCode == http.StatusOK
+	denied = resp.StatusCode == http.StatusTooManyRequests
+	valid = number(resp.Header.Get("X-RateLimit-Limit")) && number(resp.Header.Get("X-RateLimit-Remaining")) &&
+		number(resp.Header.Get("X-RateLimit-Reset")) && (!denied || number(resp.Header.Get("Retry-After")))
+
+	return allowed, denied, valid, nil
+}
+
+// number reports whether s is a non-negative integer.
+func number(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil
+}
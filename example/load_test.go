@@ -0,0 +1,62 @@
+//go:build integration
+
+package example
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	const limit = 100
+
+	before := runtime.NumGoroutine()
+
+	// The clients have room for more than the global limit, it is the one exhausted.
+	s := NewServer(Config{Limit: limit, Interval: time.Hour, KeyLimit: limit})
+	ts := httptest.NewServer(s.Handler())
+	client := ts.Client()
+
+	keys := []string{"a", "b", "c", "d"}
+	result, err := Load(context.Background(), client, ts.URL, 2*limit, 8, keys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Allowed != limit || result.Denied != limit || result.Other != 0 {
+		t.Fatalf("allowed %d, denied %d and other %d at twice the limit, want %d, %d and 0",
+			result.Allowed, result.Denied, result.Other, limit, limit)
+	}
+	if result.BadHeaders != 0 {
+		t.Fatalf("%d responses without valid rate limit headers", result.BadHeaders)
+	}
+
+	// The health check isn't limited.
+	resp, err := client.Get(ts.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("health check status %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+
+	ts.Close()
+	client.CloseIdleConnections()
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines after the shutdown, want %d\n%s",
+				runtime.NumGoroutine(), before, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
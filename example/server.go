@@ -0,0 +1,78 @@
+// Package example is a small HTTP server which wires the limiter together:
+// a global limit, a limit per client, the rate limit headers of httplimit
+// and a graceful Close. Load drives it for the integration test, run it with
+//
+//	go test -tags integration ./example
+//
+// A Prometheus collector isn't included, the module has no dependencies.
+// Limiter.Stats and Limiter.State have everything a collector exports.
+package example
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Meat-Hook/limiter"
+	"github.com/Meat-Hook/limiter/httplimit"
+)
+
+// KeyHeader is the header the clients are limited by.
+const KeyHeader = "X-Api-Key"
+
+// Config is the limits of Server.
+type Config struct {
+	// Limit is the number of requests of all the clients per Interval.
+	Limit    uint64
+	Interval time.Duration
+	// KeyLimit is the number of requests of a single client per Interval.
+	KeyLimit uint64
+}
+
+// Server serves "/" under the limits, "/healthz" isn't limited.
+type Server struct {
+	global  *limiter.Limiter
+	keyed   *limiter.Keyed
+	handler http.Handler
+}
+
+// NewServer build and returns new instance Server.
+func NewServer(cfg Config) *Server {
+	s := &Server{
+		global: limiter.New(limiter.WithRate(cfg.Limit, cfg.Interval), limiter.WithName("global")),
+		keyed:  limiter.NewKeyed(limiter.WithKeyDefaults(limiter.WithRate(cfg.KeyLimit, cfg.Interval))),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	free := func(r *http.Request) uint64 {
+		if r.URL.Path == "/healthz" {
+			return 0
+		}
+		return 1
+	}
+	s.handler = httplimit.Middleware(s.global,
+		httplimit.WithKeyed(s.keyed, httplimit.ByHeader(KeyHeader)),
+		httplimit.WithCostFunc(free),
+	)(mux)
+
+	return s
+}
+
+// Handler returns the handler of the server.
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// Close releases the limiters, the requests in flight should be finished first,
+// e.g. by http.Server.Shutdown.
+func (s *Server) Close() error {
+	s.keyed.Close()
+
+	return s.global.Close()
+}
@@ -0,0 +1,32 @@
+package limiter_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Meat-Hook/limiter"
+)
+
+// The recorded requests are replayed through a lazy limiter to see which of them
+// a limit of 3 per minute would have denied.
+func ExampleLimiter_AllowAt() {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := limiter.New(limiter.WithRate(3, time.Minute), limiter.WithLazyReset(), limiter.WithStartTime(start))
+
+	for _, offset := range []time.Duration{
+		0, 10 * time.Second, 20 * time.Second, 30 * time.Second,
+		65 * time.Second, 70 * time.Second, 75 * time.Second, 80 * time.Second,
+	} {
+		at := start.Add(offset)
+		fmt.Println(at.Format("15:04:05"), l.AllowAt(at))
+	}
+	// Output:
+	// 12:00:00 true
+	// 12:00:10 true
+	// 12:00:20 true
+	// 12:00:30 false
+	// 12:01:05 true
+	// 12:01:10 true
+	// 12:01:15 true
+	// 12:01:20 false
+}
@@ -0,0 +1,27 @@
+package limiter
+
+import "sync/atomic"
+
+// filtered reports whether a filter denied the call or the key is blocked, see Keyed.Block,
+// and counts the denial. The filters run outside the lock.
+func (l *Limiter) filtered() bool {
+	if until := atomic.LoadInt64(&l.blockedUntil); until != 0 && l.now().UnixNano() < until {
+		l.mu.Lock()
+		l.stats.Blocked++
+		l.unlock()
+
+		return true
+	}
+
+	for _, fn := range l.filters {
+		if !fn() {
+			l.mu.Lock()
+			l.stats.Filtered++
+			l.unlock()
+
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,88 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithFilter(t *testing.T) {
+	var pass atomic.Bool
+	l := New(WithRate(1, time.Minute), WithClock(newFakeClock()), WithFilter(pass.Load))
+	defer l.Close()
+
+	if l.Allow() {
+		t.Fatal("allowed with a failing filter")
+	}
+	if err := l.WaitErr(context.Background()); !errors.Is(err, ErrFiltered) {
+		t.Fatalf("got %v, want ErrFiltered", err)
+	}
+	if got := l.Stats(); got.Filtered != 2 || got.Allowed != 0 {
+		t.Fatalf("filtered %d, allowed %d, want 2 and 0", got.Filtered, got.Allowed)
+	}
+
+	// Nothing is consumed by the filtered calls.
+	pass.Store(true)
+	if !l.Allow() {
+		t.Fatal("the unit is consumed by a filtered call")
+	}
+}
+
+func TestWithFilterAfterGrant(t *testing.T) {
+	tests := []struct {
+		name    string
+		requeue bool
+	}{
+		{name: "fail"},
+		{name: "requeue", requeue: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			var fail atomic.Int32
+			opts := []Option{WithRate(1, time.Minute), WithClock(clock), WithFilter(func() bool {
+				return fail.Add(-1) < 0
+			})}
+			if tt.requeue {
+				opts = append(opts, WithFilterRequeue())
+			}
+			l := New(opts...)
+			defer l.Close()
+
+			l.Allow()
+			done := make(chan error)
+			go func() { done <- l.WaitErr(context.Background()) }()
+			eventually(t, func() bool { return l.Waiters() == 1 })
+
+			// The filter fails once when the waiter is granted.
+			fail.Store(1)
+			clock.Advance(time.Minute)
+
+			if !tt.requeue {
+				if err := <-done; !errors.Is(err, ErrFiltered) {
+					t.Fatalf("got %v, want ErrFiltered", err)
+				}
+				if got := l.Stats().Allowed; got != 1 {
+					t.Fatalf("allowed %d after the refund, want 1", got)
+				}
+				if !l.Allow() {
+					t.Fatal("the refunded unit isn't available")
+				}
+				return
+			}
+
+			if err := <-done; err != nil {
+				t.Fatalf("requeued wait got %v", err)
+			}
+			if got := l.Stats(); got.Allowed != 2 || got.Filtered != 1 {
+				t.Fatalf("allowed %d, filtered %d, want 2 and 1", got.Allowed, got.Filtered)
+			}
+			if l.Allow() {
+				t.Fatal("allowed above the limit after the requeue")
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+)
+
+// Group runs functions in goroutines paced by the limiter, similar to errgroup.Group.
+// Each function is launched only after a unit of the limiter is acquired,
+// so the group limits launches per interval rather than concurrency.
+type Group struct {
+	l      *Limiter
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	wg   sync.WaitGroup
+	once sync.Once
+	err  error
+}
+
+// NewGroup build and returns new instance Group.
+// The group context is derived from ctx and canceled when a function returns an error,
+// an acquisition fails or Wait returns.
+func NewGroup(ctx context.Context, l *Limiter) *Group {
+	l.checkNew()
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &Group{
+		l:      l,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Go waits for a unit of the limiter and calls fn in a new goroutine with the group context.
+// It blocks while the limiter is saturated. If a unit can't be acquired,
+// fn isn't called and the cause is recorded as the group error.
+func (g *Group) Go(fn func(ctx context.Context) error) {
+	if _, err := g.l.wait(g.ctx, "", 1); err != nil {
+		g.setErr(err)
+		return
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+
+		if err := fn(g.ctx); err != nil {
+			g.setErr(err)
+		}
+	}()
+}
+
+// Wait blocks until all launched functions return and returns the first error.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+
+	return g.err
+}
+
+func (g *Group) setErr(err error) {
+	g.once.Do(func() {
+		g.err = err
+		g.cancel()
+	})
+}
@@ -0,0 +1,80 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupPacing(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(2, time.Minute), WithClock(clock))
+	defer l.Close()
+
+	g := NewGroup(context.Background(), l)
+	var calls int32
+	fn := func(context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}
+
+	launched := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			g.Go(fn)
+		}
+		close(launched)
+	}()
+
+	// The third launch waits for the next window.
+	eventually(t, func() bool { return l.Waiters() == 1 && atomic.LoadInt32(&calls) == 2 })
+	clock.Advance(time.Minute)
+	<-launched
+	if err := g.Wait(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Fatalf("%d calls, want 3", got)
+	}
+}
+
+func TestGroupError(t *testing.T) {
+	errFirst := errors.New("first")
+	l := New(WithRate(10, time.Minute))
+	defer l.Close()
+
+	g := NewGroup(context.Background(), l)
+	canceled := make(chan struct{})
+	g.Go(func(context.Context) error { return errFirst })
+	g.Go(func(ctx context.Context) error {
+		// The group context is canceled by the first error.
+		<-ctx.Done()
+		close(canceled)
+		return errors.New("second")
+	})
+	<-canceled
+
+	// A function launched after the error gets the canceled context, its error is dropped.
+	g.Go(func(ctx context.Context) error {
+		if ctx.Err() == nil {
+			t.Error("the group context isn't canceled after the error")
+		}
+		return errors.New("late")
+	})
+	if err := g.Wait(); !errors.Is(err, errFirst) {
+		t.Fatalf("got %v, want the first error", err)
+	}
+}
+
+func TestGroupClosed(t *testing.T) {
+	l := New(WithRate(1, time.Minute))
+	g := NewGroup(context.Background(), l)
+	l.Close()
+
+	g.Go(func(context.Context) error { return nil })
+	if err := g.Wait(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("got %v, want ErrClosed", err)
+	}
+}
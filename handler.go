@@ -0,0 +1,59 @@
+package limiter
+
+import "context"
+
+type handlerConfig[T any] struct {
+	failFast bool
+	cost     func(T) Limit
+}
+
+// HandlerOption configures WrapHandler.
+type HandlerOption[T any] func(*handlerConfig[T])
+
+// WithHandlerFailFast makes the wrapped handler return *RateLimitError
+// instead of waiting, so the consumer can nack or requeue the message.
+func WithHandlerFailFast[T any]() HandlerOption[T] {
+	return func(c *handlerConfig[T]) {
+		c.failFast = true
+	}
+}
+
+// WithHandlerCost set the number of units a message costs, the default is one.
+// A zero cost bypasses the limiter.
+func WithHandlerCost[T any](cost func(T) Limit) HandlerOption[T] {
+	return func(c *handlerConfig[T]) {
+		c.cost = cost
+	}
+}
+
+// WrapHandler returns a message handler which waits for the limiter before calling h.
+// The wait is bounded by the handler context, its error is returned without calling h.
+// Waiters are served in FIFO order, so messages aren't reordered beyond
+// the concurrency of the caller. It is safe for concurrent use.
+func WrapHandler[T any](l *Limiter, h func(context.Context, T) error, opts ...HandlerOption[T]) func(context.Context, T) error {
+	l.checkNew()
+	cfg := handlerConfig[T]{
+		failFast: false,
+		cost:     func(T) Limit { return 1 },
+	}
+	for i := range opts {
+		opts[i](&cfg)
+	}
+
+	return func(ctx context.Context, msg T) error {
+		if n := cfg.cost(msg); n > 0 {
+			var err error
+			if cfg.failFast {
+				err = l.allowN(n)
+			} else {
+				_, err = l.wait(ctx, "", n)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return h(ctx, msg)
+	}
+}
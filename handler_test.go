@@ -0,0 +1,66 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWrapHandlerFailFast(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(3, time.Minute), WithClock(clock))
+	defer l.Close()
+
+	var handled []string
+	h := WrapHandler(l, func(_ context.Context, msg string) error {
+		handled = append(handled, msg)
+		return nil
+	}, WithHandlerFailFast[string](), WithHandlerCost(func(msg string) Limit { return Limit(len(msg)) }))
+
+	ctx := context.Background()
+	if err := h(ctx, "ab"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The message doesn't fit, the handler returns without waiting or consuming.
+	var rle *RateLimitError
+	if err := h(ctx, "cd"); !errors.As(err, &rle) || rle.RetryAfter != time.Minute {
+		t.Fatalf("got %v, want a RateLimitError with a retry in a minute", err)
+	}
+	if err := h(ctx, "e"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A zero cost bypasses the limiter.
+	if err := h(ctx, ""); err != nil {
+		t.Fatalf("unexpected error for a free message: %v", err)
+	}
+	if len(handled) != 3 || handled[0] != "ab" || handled[1] != "e" || handled[2] != "" {
+		t.Fatalf("handled %q, want ab, e and the free message", handled)
+	}
+	if got := l.Waiters(); got != 0 {
+		t.Fatalf("%d waiters in the fail fast mode", got)
+	}
+}
+
+func TestWrapHandlerWait(t *testing.T) {
+	l := New(WithRate(1, time.Hour))
+	defer l.Close()
+
+	calls := 0
+	h := WrapHandler(l, func(context.Context, int) error {
+		calls++
+		return nil
+	})
+
+	h(context.Background(), 1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := h(ctx, 2); !errors.Is(err, ErrWouldExceedDeadline) {
+		t.Fatalf("got %v, want ErrWouldExceedDeadline", err)
+	}
+	if calls != 1 {
+		t.Fatalf("%d calls, want the handler not called when the wait fails", calls)
+	}
+}
@@ -0,0 +1,84 @@
+package limiter
+
+import "time"
+
+// WindowRecord is the usage of a finished window, see WithHistory.
+type WindowRecord struct {
+	Start time.Time
+	End   time.Time
+	Used  Limit
+	// Limit is the budget of the window, the limit plus carried units.
+	Limit  Limit
+	Denied uint64
+}
+
+// history is a fixed size ring of finished windows.
+type history struct {
+	records []WindowRecord
+	head    int
+	size    int
+	// denied is Stats.Denied at the start of the current window.
+	denied uint64
+}
+
+func (h *history) push(r WindowRecord) {
+	if h.size < len(h.records) {
+		h.records[(h.head+h.size)%len(h.records)] = r
+		h.size++
+		return
+	}
+
+	h.records[h.head] = r
+	h.head = (h.head + 1) % len(h.records)
+}
+
+// recordWindowsLocked records the windows finished by now before the usage is reset.
+// Idle windows after the current one are recorded with no usage, up to the history size.
+func (l *Limiter) recordWindowsLocked(now time.Time) {
+	elapsed := now.Sub(l.windowStart)
+	if l.history == nil || l.external || elapsed < l.interval {
+		return
+	}
+
+	record := WindowRecord{
+		Start:  l.windowStart,
+		End:    l.windowStart.Add(l.interval),
+		Used:   l.current,
+		Limit:  l.budgetLocked(),
+		Denied: l.stats.Denied - l.history.denied,
+	}
+	l.history.denied = l.stats.Denied
+
+	windows := int64(elapsed / l.interval)
+	if size := int64(len(l.history.records)); windows > size {
+		windows = size
+		record.Start = l.windowStart.Add(time.Duration(int64(elapsed/l.interval)-size) * l.interval)
+		record.End = record.Start.Add(l.interval)
+		record.Used, record.Denied = 0, 0
+	}
+
+	l.history.push(record)
+	for i := int64(1); i < windows; i++ {
+		record = WindowRecord{Start: record.End, End: record.End.Add(l.interval), Limit: l.limit}
+		l.history.push(record)
+	}
+}
+
+// History returns the finished windows from the oldest to the newest, see WithHistory.
+// It is still available after Close.
+func (l *Limiter) History() []WindowRecord {
+	l.checkNew()
+	l.lock()
+	defer l.unlock()
+
+	if l.history == nil {
+		return nil
+	}
+
+	records := make([]WindowRecord, l.history.size)
+	for i := range records {
+		records[i] = l.history.records[(l.history.head+i)%len(l.history.records)]
+	}
+
+	return records
+}
@@ -0,0 +1,49 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithHistory(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.Now()
+	l := New(WithRate(5, time.Minute), WithClock(clock), WithHistory(3))
+
+	allowed(l, 3)
+	clock.Advance(time.Minute)
+	allowed(l, 7)
+	clock.Advance(time.Minute)
+	allowed(l, 1)
+
+	window := func(i int) (time.Time, time.Time) {
+		from := start.Add(time.Duration(i) * time.Minute)
+		return from, from.Add(time.Minute)
+	}
+	record := func(i int, used Limit, denied uint64) WindowRecord {
+		from, to := window(i)
+		return WindowRecord{Start: from, End: to, Used: used, Limit: 5, Denied: denied}
+	}
+
+	assert := func(want ...WindowRecord) {
+		t.Helper()
+		got := l.History()
+		if len(got) != len(want) {
+			t.Fatalf("history %+v, want %+v", got, want)
+		}
+		for i := range want {
+			if !got[i].Start.Equal(want[i].Start) || !got[i].End.Equal(want[i].End) ||
+				got[i].Used != want[i].Used || got[i].Limit != want[i].Limit || got[i].Denied != want[i].Denied {
+				t.Fatalf("record %d is %+v, want %+v", i, got[i], want[i])
+			}
+		}
+	}
+	assert(record(0, 3, 0), record(1, 5, 2))
+
+	// Idle windows are recorded too, the ring keeps the newest.
+	clock.Advance(3 * time.Minute)
+	assert(record(2, 1, 0), record(3, 0, 0), record(4, 0, 0))
+
+	l.Close()
+	assert(record(2, 1, 0), record(3, 0, 0), record(4, 0, 0))
+}
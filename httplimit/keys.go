@@ -0,0 +1,114 @@
+// Package httplimit contains helpers for limiting HTTP requests.
+package httplimit
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ErrNoKey is returned when a request has nothing to be keyed on.
+var ErrNoKey = errors.New("httplimit: no key in request")
+
+// KeyFunc returns the key a request is limited by.
+type KeyFunc func(r *http.Request) (string, error)
+
+// ByRemoteAddr returns KeyFunc which keys requests on the host of RemoteAddr.
+// Behind a proxy it is the proxy address, see ByClientIP.
+func ByRemoteAddr() KeyFunc {
+	return func(r *http.Request) (string, error) {
+		return remoteHost(r)
+	}
+}
+
+// ByHeader returns KeyFunc which keys requests on the header value, e.g. an API key.
+func ByHeader(name string) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		key := strings.TrimSpace(r.Header.Get(name))
+		if key == "" {
+			return "", ErrNoKey
+		}
+
+		return key, nil
+	}
+}
+
+// ByClientIP returns KeyFunc which keys requests on the client IP.
+// If the request comes from a trusted proxy, X-Forwarded-For is walked from the right
+// skipping trusted hops and the first untrusted address is used.
+// Malformed entries make the chain untrustworthy and RemoteAddr is used instead.
+func ByClientIP(trustedProxies []netip.Prefix) KeyFunc {
+	trusted := func(addr netip.Addr) bool {
+		for _, p := range trustedProxies {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return func(r *http.Request) (string, error) {
+		host, err := remoteHost(r)
+		if err != nil {
+			return "", err
+		}
+
+		remote, err := netip.ParseAddr(host)
+		if err != nil || !trusted(remote.Unmap()) {
+			return host, nil
+		}
+
+		hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		client := remote.Unmap()
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" && len(hops) == 1 {
+				break
+			}
+
+			addr, ok := parseHop(hop)
+			if !ok {
+				return host, nil
+			}
+
+			client = addr
+			if !trusted(addr) {
+				break
+			}
+		}
+
+		return client.String(), nil
+	}
+}
+
+// remoteHost returns the host part of RemoteAddr.
+func remoteHost(r *http.Request) (string, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if host == "" {
+		return "", ErrNoKey
+	}
+
+	return host, nil
+}
+
+// parseHop parses an X-Forwarded-For entry, with or without a port,
+// an IPv6 address may be in brackets without a port too.
+func parseHop(hop string) (netip.Addr, bool) {
+	if strings.HasPrefix(hop, "[") && strings.HasSuffix(hop, "]") {
+		hop = hop[1 : len(hop)-1]
+	}
+	if addr, err := netip.ParseAddr(hop); err == nil {
+		return addr.Unmap(), true
+	}
+	if addrPort, err := netip.ParseAddrPort(hop); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+
+	return netip.Addr{}, false
+}
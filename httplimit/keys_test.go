@@ -0,0 +1,76 @@
+package httplimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestByClientIP(t *testing.T) {
+	key := ByClientIP([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")})
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{name: "no header", remote: "10.0.0.1:1234", want: "10.0.0.1"},
+		{name: "untrusted remote", remote: "203.0.113.5:1234", xff: []string{"198.51.100.7"}, want: "203.0.113.5"},
+		{name: "single hop", remote: "10.0.0.1:1234", xff: []string{"198.51.100.7"}, want: "198.51.100.7"},
+		{name: "multiple hops", remote: "10.0.0.1:1234", xff: []string{"198.51.100.7, 10.0.0.2"}, want: "198.51.100.7"},
+		{name: "multiple headers", remote: "10.0.0.1:1234", xff: []string{"198.51.100.7", "10.0.0.2"}, want: "198.51.100.7"},
+		{name: "spoofed leftmost", remote: "10.0.0.1:1234", xff: []string{"1.1.1.1, 198.51.100.7, 10.0.0.2"}, want: "198.51.100.7"},
+		{name: "all trusted", remote: "10.0.0.1:1234", xff: []string{"10.0.0.3, 10.0.0.2"}, want: "10.0.0.3"},
+		{name: "hop with port", remote: "10.0.0.1:1234", xff: []string{"198.51.100.7:5555"}, want: "198.51.100.7"},
+		{name: "malformed hop", remote: "10.0.0.1:1234", xff: []string{"198.51.100.7, garbage"}, want: "10.0.0.1"},
+		{name: "mapped remote", remote: "[::ffff:10.0.0.1]:1234", xff: []string{"198.51.100.7"}, want: "198.51.100.7"},
+		{name: "ipv6 remote", remote: "[2001:db8::1]:443", want: "2001:db8::1"},
+		{name: "ipv6 hop", remote: "[fd00::1]:443", xff: []string{"2001:db8::7"}, want: "2001:db8::7"},
+		{name: "ipv6 hop in brackets", remote: "[fd00::1]:443", xff: []string{"[2001:db8::7]"}, want: "2001:db8::7"},
+		{name: "ipv6 hop with port", remote: "[fd00::1]:443", xff: []string{"[2001:db8::7]:8080, fd00::2"}, want: "2001:db8::7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+
+			got, err := key(r)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("key %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoKey(t *testing.T) {
+	tests := []struct {
+		name string
+		key  KeyFunc
+	}{
+		{name: "remote addr", key: ByRemoteAddr()},
+		{name: "client ip", key: ByClientIP(nil)},
+		{name: "header", key: ByHeader("X-Api-Key")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = ""
+			r.Header.Set("X-Api-Key", " ")
+
+			if _, err := tt.key(r); !errors.Is(err, ErrNoKey) {
+				t.Fatalf("got error %v, want %v", err, ErrNoKey)
+			}
+		})
+	}
+}
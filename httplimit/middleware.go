@@ -0,0 +1,286 @@
+package httplimit
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Meat-Hook/limiter"
+)
+
+type config struct {
+	route       LimiterFunc
+	keyed       *limiter.Keyed
+	key         KeyFunc
+	concurrency *limiter.Concurrency
+	denied      http.Handler
+	status      int
+	maxDelay    time.Duration
+	maxQueued   int64
+	cost        func(*http.Request) uint64
+}
+
+// Option configures Middleware.
+type Option func(*config)
+
+// LimiterFunc returns the limiter of the route a request is charged against,
+// nil for the default one passed to Middleware or Unlimited.
+type LimiterFunc func(r *http.Request) *limiter.Limiter
+
+// Unlimited is returned by LimiterFunc for the routes which are exempt
+// from the default limiter too. It must not be used as a limiter.
+var Unlimited = new(limiter.Limiter)
+
+// WithLimiterFunc charges every request against the limiter fn selects for it
+// instead of the one passed to Middleware. It is called before any units are taken,
+// WithKeyed and WithConcurrency still apply to all the routes.
+func WithLimiterFunc(fn LimiterFunc) Option {
+	return func(c *config) {
+		c.route = fn
+	}
+}
+
+// WithKeyed limits every client by its limiter in k too, the client is identified by key.
+// Requests the key can't be extracted from are rejected with 400 Bad Request.
+func WithKeyed(k *limiter.Keyed, key KeyFunc) Option {
+	return func(c *config) {
+		c.keyed = k
+		c.key = key
+	}
+}
+
+// WithConcurrency limits the requests in flight by c too.
+// A request is rejected like a limited one if all slots are taken,
+// the slot is released when the handler returns.
+func WithConcurrency(c *limiter.Concurrency) Option {
+	return func(cfg *config) {
+		cfg.concurrency = c
+	}
+}
+
+// WithDeniedHandler set the handler which writes the response to a limited request.
+// The rate limit headers are already set when it is called.
+// The default handler writes the status of WithDeniedStatus.
+func WithDeniedHandler(h http.Handler) Option {
+	return func(c *config) {
+		c.denied = h
+	}
+}
+
+// WithDeniedStatus set the status the default denied handler writes,
+// 429 Too Many Requests by default. 503 Service Unavailable suits internal services better.
+func WithDeniedStatus(code int) Option {
+	return func(c *config) {
+		c.status = code
+	}
+}
+
+// WithMaxDelay queues a limited request instead of rejecting it at once. It waits
+// for the limiters as long as d and the request context allow, and is rejected if
+// the units don't come in time. A queued request keeps its concurrency slot.
+// It panics if d is not positive.
+func WithMaxDelay(d time.Duration) Option {
+	if d <= 0 {
+		panic("httplimit: WithMaxDelay d must be positive")
+	}
+
+	return func(c *config) {
+		c.maxDelay = d
+	}
+}
+
+// WithMaxQueued caps the requests waiting with WithMaxDelay, the ones above it are rejected at once.
+// Without it the queue isn't capped. It panics if n is not positive.
+func WithMaxQueued(n int) Option {
+	if n <= 0 {
+		panic("httplimit: WithMaxQueued n must be positive")
+	}
+
+	return func(c *config) {
+		c.maxQueued = int64(n)
+	}
+}
+
+// WithCostFunc set how many units a request takes from every limiter, one by default.
+// The units are taken at once or not at all, a denied request gets Retry-After
+// of when that many units are available. A zero cost bypasses the limiters
+// and the concurrency limit, e.g. for health checks.
+func WithCostFunc(cost func(*http.Request) uint64) Option {
+	return func(c *config) {
+		c.cost = cost
+	}
+}
+
+// Middleware returns a middleware which takes a unit of l for every request, see WithCostFunc,
+// and rejects the request if there is none, or queues it, see WithMaxDelay. The X-RateLimit-Limit, X-RateLimit-Remaining
+// and X-RateLimit-Reset headers describe the most exhausted of the limiters,
+// X-RateLimit-Reset is the number of seconds until its window resets.
+// Rejected requests also get Retry-After. The l may be nil if WithLimiterFunc,
+// WithKeyed or WithConcurrency is set.
+func Middleware(l *limiter.Limiter, opts ...Option) func(http.Handler) http.Handler {
+	cfg := config{
+		route:       nil,
+		keyed:       nil,
+		key:         nil,
+		concurrency: nil,
+		denied:      nil,
+		status:      http.StatusTooManyRequests,
+		maxDelay:    0,
+		maxQueued:   0,
+		cost:        func(*http.Request) uint64 { return 1 },
+	}
+	for i := range opts {
+		opts[i](&cfg)
+	}
+	if cfg.denied == nil {
+		cfg.denied = statusHandler(cfg.status)
+	}
+
+	if l == nil && cfg.route == nil && cfg.keyed == nil && cfg.concurrency == nil {
+		panic("httplimit: Middleware requires a limiter")
+	}
+
+	// queued counts the requests waiting with WithMaxDelay.
+	var queued int64
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cost := limiter.Limit(cfg.cost(r))
+			if cost == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			route := l
+			if cfg.route != nil {
+				switch selected := cfg.route(r); selected {
+				case nil:
+				case Unlimited:
+					route = nil
+				default:
+					route = selected
+				}
+			}
+
+			ls := make([]*limiter.Limiter, 0, 2)
+			if route != nil {
+				ls = append(ls, route)
+			}
+
+			if cfg.keyed != nil {
+				key, err := cfg.key(r)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+
+				client := cfg.keyed.Get(key)
+				if client == nil {
+					// The keyed limiters are closed.
+					cfg.denied.ServeHTTP(w, r)
+					return
+				}
+				ls = append(ls, client)
+			}
+
+			if cfg.concurrency != nil {
+				if !cfg.concurrency.TryAcquire() {
+					cfg.denied.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			allowed := limiter.AllowAllN(cost, ls...)
+			if !allowed && cfg.maxDelay > 0 {
+				allowed = cfg.wait(r.Context(), &queued, cost, ls)
+			}
+			setHeaders(w.Header(), ls, !allowed, cost)
+			if !allowed {
+				// The slot isn't held while the rejection is written.
+				if cfg.concurrency != nil {
+					cfg.concurrency.Release()
+				}
+				cfg.denied.ServeHTTP(w, r)
+				return
+			}
+			if cfg.concurrency != nil {
+				defer cfg.concurrency.Release()
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// wait waits for n units of every limiter within the delay and the queue cap.
+func (c *config) wait(ctx context.Context, queued *int64, n limiter.Limit, ls []*limiter.Limiter) bool {
+	defer atomic.AddInt64(queued, -1)
+	if n := atomic.AddInt64(queued, 1); c.maxQueued > 0 && n > c.maxQueued {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.maxDelay)
+	defer cancel()
+
+	return limiter.WaitAllN(ctx, n, ls...)
+}
+
+// setHeaders sets the rate limit headers of the limiter with the fewest remaining units,
+// and Retry-After of the limiter which frees n units last if denied is set.
+func setHeaders(h http.Header, ls []*limiter.Limiter, denied bool, n limiter.Limit) {
+	var (
+		state   limiter.State
+		exposed *limiter.Limiter
+	)
+	for _, l := range ls {
+		s := l.State()
+		if s.Limit == limiter.Infinite {
+			continue
+		}
+		if exposed == nil || s.Remaining < state.Remaining {
+			state, exposed = s, l
+		}
+	}
+
+	if exposed != nil {
+		// The reset is measured by the clock of the limiter, it may not be the system one.
+		_, _, resetIn := exposed.Usage()
+		h.Set("X-RateLimit-Limit", strconv.FormatUint(uint64(state.Limit), 10))
+		h.Set("X-RateLimit-Remaining", strconv.FormatUint(uint64(state.Remaining), 10))
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(seconds(resetIn), 10))
+	}
+
+	if !denied {
+		return
+	}
+
+	var retryAfter time.Duration
+	for _, l := range ls {
+		d := l.RetryAfterN(n)
+		if d == limiter.Never {
+			return
+		}
+		if d > retryAfter {
+			retryAfter = d
+		}
+	}
+	h.Set("Retry-After", strconv.FormatInt(seconds(retryAfter), 10))
+}
+
+// seconds rounds d up to whole seconds, negative d is zero.
+func seconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+
+	return int64((d + time.Second - 1) / time.Second)
+}
+
+// statusHandler returns the default denied handler which writes code.
+func statusHandler(code int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, http.StatusText(code), code)
+	})
+}
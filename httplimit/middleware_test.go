@@ -0,0 +1,396 @@
+package httplimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Meat-Hook/limiter"
+)
+
+// testClock is a limiter.Clock which moves only when the test advances it.
+// Its timers never fire, the limiters apply resets on access.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *testClock) After(time.Duration) <-chan time.Time {
+	return nil
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// serve sends a request with the X-Key header set to key, if it is not empty.
+func serve(h http.Handler, key string) *httptest.ResponseRecorder {
+	return servePath(h, "/", key)
+}
+
+// servePath is serve for the path.
+func servePath(h http.Handler, path, key string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	if key != "" {
+		r.Header.Set("X-Key", key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+// checkHeaders fails the test if the rate limit headers of w differ, an empty want is an unset header.
+func checkHeaders(t *testing.T, w *httptest.ResponseRecorder, limit, remaining, reset, retryAfter string) {
+	t.Helper()
+
+	for name, want := range map[string]string{
+		"X-RateLimit-Limit":     limit,
+		"X-RateLimit-Remaining": remaining,
+		"X-RateLimit-Reset":     reset,
+		"Retry-After":           retryAfter,
+	} {
+		if got := w.Header().Get(name); got != want {
+			t.Errorf("%s is %q, want %q", name, got, want)
+		}
+	}
+}
+
+var noContent = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+})
+
+func TestMiddleware(t *testing.T) {
+	clock := newTestClock()
+	l := limiter.New(limiter.WithRate(2, time.Minute), limiter.WithClock(clock), limiter.WithLazyReset())
+	defer l.Close()
+	h := Middleware(l)(noContent)
+
+	w := serve(h, "")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusNoContent)
+	}
+	checkHeaders(t, w, "2", "1", "60", "")
+
+	// The reset follows the clock of the limiter.
+	clock.Advance(15 * time.Second)
+	serve(h, "")
+	w = serve(h, "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status %d over the limit, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	checkHeaders(t, w, "2", "0", "45", "45")
+
+	clock.Advance(45 * time.Second)
+	if w = serve(h, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("status %d after the reset, want %d", w.Code, http.StatusNoContent)
+	}
+	checkHeaders(t, w, "2", "1", "60", "")
+}
+
+func TestMiddlewareDeniedHandler(t *testing.T) {
+	clock := newTestClock()
+	l := limiter.New(limiter.WithRate(1, time.Minute), limiter.WithClock(clock), limiter.WithLazyReset())
+	defer l.Close()
+	c := limiter.NewConcurrency(1)
+
+	var headers http.Header
+	denied := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		headers = w.Header().Clone()
+		if !c.TryAcquire() {
+			t.Error("the slot of the denied request is held by the denied handler")
+		} else {
+			c.Release()
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	h := Middleware(l, WithConcurrency(c), WithDeniedHandler(denied))(noContent)
+
+	serve(h, "")
+	w := serve(h, "")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want the denied handler's %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if headers.Get("Retry-After") != "60" || headers.Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("denied handler sees headers %v, want them set", headers)
+	}
+	if n := c.InFlight(); n != 0 {
+		t.Fatalf("%d requests in flight after they are served, want 0", n)
+	}
+}
+
+func TestMiddlewareKeyed(t *testing.T) {
+	clock := newTestClock()
+	global := limiter.New(limiter.WithRate(3, time.Minute), limiter.WithClock(clock), limiter.WithLazyReset())
+	defer global.Close()
+	k := limiter.NewKeyed(limiter.WithKeyDefaults(limiter.WithRate(2, 30*time.Second), limiter.WithClock(clock), limiter.WithLazyReset()))
+	defer k.Close()
+	h := Middleware(global, WithKeyed(k, ByHeader("X-Key")))(noContent)
+
+	w := serve(h, "a")
+	checkHeaders(t, w, "2", "1", "30", "")
+	serve(h, "a")
+
+	// The key is exhausted first, the headers describe it and the global limit isn't taken.
+	w = serve(h, "a")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status %d over the key limit, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	checkHeaders(t, w, "2", "0", "30", "30")
+	if used := global.Used(); used != 2 {
+		t.Fatalf("global used %d, want 2", used)
+	}
+
+	// Another key has its own limit, but the global one runs out.
+	if w = serve(h, "b"); w.Code != http.StatusNoContent {
+		t.Fatalf("status %d for another key, want %d", w.Code, http.StatusNoContent)
+	}
+	checkHeaders(t, w, "3", "0", "60", "")
+	w = serve(h, "b")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status %d over the global limit, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	checkHeaders(t, w, "3", "0", "60", "60")
+
+	if w = serve(h, ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d without a key, want %d", w.Code, http.StatusBadRequest)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "" {
+		t.Fatalf("Retry-After %q on a request without a key, want none", retryAfter)
+	}
+}
+
+func TestMiddlewareMaxDelay(t *testing.T) {
+	t.Run("proceed", func(t *testing.T) {
+		l := limiter.New(limiter.WithRate(1, 50*time.Millisecond))
+		defer l.Close()
+		h := Middleware(l, WithMaxDelay(time.Second))(noContent)
+
+		serve(h, "")
+		start := time.Now()
+		if w := serve(h, ""); w.Code != http.StatusNoContent {
+			t.Fatalf("status %d after waiting, want %d", w.Code, http.StatusNoContent)
+		}
+		if waited := time.Since(start); waited < 10*time.Millisecond {
+			t.Fatalf("served after %v, want it to wait for the reset", waited)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		l := limiter.New(limiter.WithRate(1, time.Hour))
+		defer l.Close()
+		h := Middleware(l, WithMaxDelay(10*time.Millisecond), WithDeniedStatus(http.StatusServiceUnavailable))(noContent)
+
+		serve(h, "")
+		w := serve(h, "")
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("status %d after the delay, want %d", w.Code, http.StatusServiceUnavailable)
+		}
+		if got := w.Header().Get("Retry-After"); got != "3600" {
+			t.Fatalf("Retry-After %q, want 3600", got)
+		}
+	})
+
+	t.Run("queue full", func(t *testing.T) {
+		l := limiter.New(limiter.WithRate(1, 300*time.Millisecond))
+		defer l.Close()
+		h := Middleware(l, WithMaxDelay(time.Second), WithMaxQueued(1))(noContent)
+
+		serve(h, "")
+		queued := make(chan int)
+		go func() { queued <- serve(h, "").Code }()
+		deadline := time.Now().Add(time.Second)
+		for l.Waiters() == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("the request isn't queued")
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		start := time.Now()
+		if w := serve(h, ""); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("status %d above the queue cap, want %d", w.Code, http.StatusTooManyRequests)
+		}
+		if waited := time.Since(start); waited > 100*time.Millisecond {
+			t.Fatalf("rejected after %v above the queue cap, want at once", waited)
+		}
+		if code := <-queued; code != http.StatusNoContent {
+			t.Fatalf("status %d of the queued request, want %d", code, http.StatusNoContent)
+		}
+	})
+}
+
+func TestMiddlewareCost(t *testing.T) {
+	cost := WithCostFunc(func(r *http.Request) uint64 {
+		switch r.URL.Path {
+		case "/export":
+			return 25
+		case "/health":
+			return 0
+		}
+		return 1
+	})
+
+	t.Run("window", func(t *testing.T) {
+		clock := newTestClock()
+		l := limiter.New(limiter.WithRate(30, time.Minute), limiter.WithClock(clock), limiter.WithLazyReset())
+		defer l.Close()
+		h := Middleware(l, cost)(noContent)
+
+		w := servePath(h, "/export", "")
+		checkHeaders(t, w, "30", "5", "60", "")
+		for i := 0; i < 5; i++ {
+			servePath(h, "/read", "")
+		}
+
+		// A zero cost isn't limited and gets no headers.
+		if w = servePath(h, "/health", ""); w.Code != http.StatusNoContent {
+			t.Fatalf("status %d of a free request, want %d", w.Code, http.StatusNoContent)
+		}
+		checkHeaders(t, w, "", "", "", "")
+
+		clock.Advance(10 * time.Second)
+		if w = servePath(h, "/read", ""); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("status %d over the limit, want %d", w.Code, http.StatusTooManyRequests)
+		}
+		checkHeaders(t, w, "30", "0", "50", "50")
+		if used := l.Used(); used != 30 {
+			t.Fatalf("used %d, want 30", used)
+		}
+	})
+
+	t.Run("recovery", func(t *testing.T) {
+		// A unit is restored every 2 seconds.
+		clock := newTestClock()
+		l := limiter.New(limiter.WithRate(30, time.Minute), limiter.WithGradualRecovery(0, 0),
+			limiter.WithClock(clock), limiter.WithLazyReset())
+		defer l.Close()
+		h := Middleware(l, cost)(noContent)
+
+		servePath(h, "/export", "")
+		w := servePath(h, "/export", "")
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("status %d of a cost above the remaining units, want %d", w.Code, http.StatusTooManyRequests)
+		}
+		// 20 more units are restored in 40 seconds, a single one would be there in 2.
+		if got := w.Header().Get("Retry-After"); got != "40" {
+			t.Fatalf("Retry-After %q, want 40", got)
+		}
+		if used := l.Used(); used != 25 {
+			t.Fatalf("used %d after the denied cost, want 25", used)
+		}
+
+		clock.Advance(40 * time.Second)
+		if w = servePath(h, "/export", ""); w.Code != http.StatusNoContent {
+			t.Fatalf("status %d when the units are restored, want %d", w.Code, http.StatusNoContent)
+		}
+	})
+}
+
+func TestMiddlewareRoutes(t *testing.T) {
+	clock := newTestClock()
+	newLimiter := func(limit uint64) *limiter.Limiter {
+		return limiter.New(limiter.WithRate(limit, time.Minute), limiter.WithClock(clock), limiter.WithLazyReset())
+	}
+	search, export, fallback := newLimiter(3), newLimiter(1), newLimiter(2)
+	defer search.Close()
+	defer export.Close()
+	defer fallback.Close()
+
+	h := Middleware(fallback, WithLimiterFunc(func(r *http.Request) *limiter.Limiter {
+		switch r.URL.Path {
+		case "/search":
+			return search
+		case "/export":
+			return export
+		case "/health":
+			return Unlimited
+		}
+		return nil
+	}))(noContent)
+
+	// Every route is exhausted on its own, the headers describe its limiter.
+	w := servePath(h, "/export", "")
+	checkHeaders(t, w, "1", "0", "60", "")
+	w = servePath(h, "/export", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status %d over the export limit, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	checkHeaders(t, w, "1", "0", "60", "60")
+
+	for i := 0; i < 3; i++ {
+		if w = servePath(h, "/search", ""); w.Code != http.StatusNoContent {
+			t.Fatalf("status %d of search %d with export exhausted, want %d", w.Code, i, http.StatusNoContent)
+		}
+	}
+	checkHeaders(t, w, "3", "0", "60", "")
+	if w = servePath(h, "/search", ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status %d over the search limit, want %d", w.Code, http.StatusTooManyRequests)
+	}
+
+	if w = servePath(h, "/other", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("status %d of an unmatched route, want %d", w.Code, http.StatusNoContent)
+	}
+	checkHeaders(t, w, "2", "1", "60", "")
+
+	for i := 0; i < 5; i++ {
+		if w = servePath(h, "/health", ""); w.Code != http.StatusNoContent {
+			t.Fatalf("status %d of an unlimited route, want %d", w.Code, http.StatusNoContent)
+		}
+	}
+	checkHeaders(t, w, "", "", "", "")
+	if used := fallback.Used(); used != 1 {
+		t.Fatalf("default limiter used %d, want 1", used)
+	}
+}
+
+// BenchmarkMiddleware serves a request through the middleware end to end,
+// see the benchmarks of the limiter package for how to run them.
+func BenchmarkMiddleware(b *testing.B) {
+	const limit = 1 << 62
+
+	l := limiter.New(limiter.WithRate(limit, time.Hour))
+	defer l.Close()
+	k := limiter.NewKeyed(limiter.WithKeyDefaults(limiter.WithRate(limit, time.Hour)))
+	defer k.Close()
+
+	benchmarks := []struct {
+		name    string
+		handler http.Handler
+	}{
+		{name: "limiter", handler: Middleware(l)(noContent)},
+		{name: "keyed", handler: Middleware(l, WithKeyed(k, ByRemoteAddr()))(noContent)},
+	}
+
+	for _, bench := range benchmarks {
+		b.Run(bench.name, func(b *testing.B) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				bench.handler.ServeHTTP(w, r)
+				if w.Code != http.StatusNoContent {
+					b.Fatalf("status %d", w.Code)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,459 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// If you don't send option the WithIdleTimeout, Keyed will use this timeout.
+const defaultIdleTimeout = 10 * time.Minute
+
+// KeyedOption configures Keyed, see NewKeyed.
+type KeyedOption func(*Keyed)
+
+// WithKeyDefaults set Keyed.defaults, the options of every key limiter.
+func WithKeyDefaults(opts ...Option) KeyedOption {
+	return func(k *Keyed) {
+		k.defaults = append(k.defaults, opts...)
+	}
+}
+
+// WithKeyOptions set Keyed.keyOptions.
+// The options returned for a key are applied after the defaults when its limiter is created.
+func WithKeyOptions(fn func(key string) []Option) KeyedOption {
+	return func(k *Keyed) {
+		k.keyOptions = fn
+	}
+}
+
+// WithIdleTimeout set Keyed.idle.
+// A key unused for the timeout is evicted once its limiter has no usage and no waiters,
+// so an evicted key never gets a fresh limit early.
+func WithIdleTimeout(idle time.Duration) KeyedOption {
+	return func(k *Keyed) {
+		k.idle = idle
+	}
+}
+
+// WithKeyAlignment set Keyed.alignment.
+// The windows of a key are every long, counted in whole days from the local midnight of loc,
+// e.g. daily quotas reset at the midnight of every customer. The day boundaries use time.Date,
+// so the window around a DST change is 23 or 25 hours long, NextReset and RetryAfter report
+// the local midnight. A zero every keeps the key on its own interval. It panics when
+// the limiter of a key is created if every is not a whole number of days, loc is nil or
+// the key uses gradual recovery, the sliding log, a shared schedule or external refill.
+func WithKeyAlignment(fn func(key string) (every time.Duration, loc *time.Location)) KeyedOption {
+	return func(k *Keyed) {
+		k.alignment = fn
+	}
+}
+
+// keyedShards is the number of shards of Keyed, the keys are spread over them by a hash,
+// so calls with different keys rarely contend for the same lock.
+const keyedShards = 32
+
+// Keyed keeps a limiter per key, e.g. per user or IP, and creates them on first use.
+// The key limiters use lazy reset, so they don't run goroutines, idle keys are evicted
+// by a single cleanup goroutine. It must be closed.
+type Keyed struct {
+	shards     [keyedShards]keyedShard
+	defaults   []Option
+	keyOptions func(key string) []Option
+	alignment  func(key string) (time.Duration, *time.Location)
+	sketch     *sketch
+	idle       time.Duration
+	done       chan struct{}
+}
+
+// keyedShard is a part of the keys of Keyed with its own lock.
+type keyedShard struct {
+	mu   sync.RWMutex
+	keys map[string]*keyedEntry
+	// configs are set by SetKeyConfig, they outlive the evicted keys.
+	configs map[string]KeyConfig
+	closed  bool
+}
+
+type keyedEntry struct {
+	l *Limiter
+	// lastUsed is the monotonic time of the last call with the key.
+	lastUsed int64
+	// active is the number of calls in progress, the entry isn't evicted while they run.
+	active int32
+}
+
+// NewKeyed build and returns new instance Keyed.
+// It panics if the idle timeout is not positive.
+func NewKeyed(opts ...KeyedOption) *Keyed {
+	k := &Keyed{
+		idle: defaultIdleTimeout,
+		done: make(chan struct{}),
+	}
+	for i := range k.shards {
+		k.shards[i].keys = make(map[string]*keyedEntry)
+		k.shards[i].configs = make(map[string]KeyConfig)
+	}
+
+	for i := range opts {
+		opts[i](k)
+	}
+
+	if k.idle <= 0 {
+		panic("limiter: idle timeout must be positive")
+	}
+	if k.sketch != nil {
+		k.sketch.init(k.defaults)
+	}
+
+	go k.evictIdle()
+
+	return k
+}
+
+// Allow is Limiter.Allow for the key.
+// It returns false after Close.
+func (k *Keyed) Allow(key string) bool {
+	if k.sketch != nil {
+		return !k.closed() && k.sketch.allow(key, 1)
+	}
+
+	e := k.acquire(key)
+	if e == nil {
+		return false
+	}
+	defer atomic.AddInt32(&e.active, -1)
+
+	return e.l.Allow()
+}
+
+// Wait is Limiter.Wait for the key.
+// With WithApproximateKeys a denied call waits for the next window and tries again.
+// It returns false after Close.
+func (k *Keyed) Wait(ctx context.Context, key string) bool {
+	if k.sketch != nil {
+		for !k.closed() {
+			if k.sketch.allow(key, 1) {
+				return true
+			}
+			if !k.sketch.wait(ctx, k.done) {
+				return false
+			}
+		}
+
+		return false
+	}
+
+	e := k.acquire(key)
+	if e == nil {
+		return false
+	}
+	defer atomic.AddInt32(&e.active, -1)
+
+	return e.l.Wait(ctx)
+}
+
+// Get returns the limiter of the key, creating it if it doesn't exist.
+// The limiter may be evicted later while the caller holds it, use Allow and Wait
+// of Keyed to keep the usage. It returns nil after Close and with WithApproximateKeys.
+func (k *Keyed) Get(key string) *Limiter {
+	if k.sketch != nil {
+		return nil
+	}
+
+	e := k.acquire(key)
+	if e == nil {
+		return nil
+	}
+	atomic.AddInt32(&e.active, -1)
+
+	return e.l
+}
+
+// Len returns the number of keys.
+func (k *Keyed) Len() int {
+	n := 0
+	for i := range k.shards {
+		sh := &k.shards[i]
+		sh.mu.RLock()
+		n += len(sh.keys)
+		sh.mu.RUnlock()
+	}
+
+	return n
+}
+
+// Close stops the cleanup goroutine and removes all keys.
+// Waiters blocked on them are released and fail.
+func (k *Keyed) Close() {
+	for i := range k.shards {
+		k.shards[i].mu.Lock()
+	}
+	defer func() {
+		for i := range k.shards {
+			k.shards[i].mu.Unlock()
+		}
+	}()
+
+	if k.shards[0].closed {
+		return
+	}
+
+	for i := range k.shards {
+		sh := &k.shards[i]
+		sh.closed = true
+		for _, e := range sh.keys {
+			e.l.Close()
+		}
+		sh.keys = make(map[string]*keyedEntry)
+	}
+	close(k.done)
+}
+
+// closed reports whether Close was called.
+func (k *Keyed) closed() bool {
+	select {
+	case <-k.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// withReleased set Limiter.released, the waiters of the key limiters fail when done is closed.
+func withReleased(done <-chan struct{}) Option {
+	return func(l *Limiter) {
+		l.released = done
+	}
+}
+
+// shard returns the shard of the key, the hash is FNV-1a.
+func (k *Keyed) shard(key string) *keyedShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+
+	return &k.shards[h%keyedShards]
+}
+
+// acquire returns the entry of the key marked as active, nil after Close.
+func (k *Keyed) acquire(key string) *keyedEntry {
+	now := keyedNow()
+	sh := k.shard(key)
+
+	sh.mu.RLock()
+	e, ok := sh.keys[key]
+	if ok {
+		atomic.AddInt32(&e.active, 1)
+		atomic.StoreInt64(&e.lastUsed, now)
+	}
+	closed := sh.closed
+	sh.mu.RUnlock()
+
+	if ok || closed {
+		return e
+	}
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if sh.closed {
+		return nil
+	}
+
+	e = k.entryLocked(sh, key, now)
+	atomic.AddInt32(&e.active, 1)
+
+	return e
+}
+
+// entryLocked returns the entry of the key from its shard, creating it with extra options
+// after the defaults, the key options and the key config if it doesn't exist.
+func (k *Keyed) entryLocked(sh *keyedShard, key string, now int64, extra ...Option) *keyedEntry {
+	e, ok := sh.keys[key]
+	if !ok {
+		opts := k.keyOptionsLocked(sh, key)
+		opts = append(opts, extra...)
+		opts = append(opts, WithName(key), WithLazyReset(), withReleased(k.done))
+
+		e = &keyedEntry{l: New(opts...)}
+		sh.keys[key] = e
+	}
+	atomic.StoreInt64(&e.lastUsed, now)
+
+	return e
+}
+
+// keyOptionsLocked returns the defaults, the key options, the config and the alignment of the key.
+func (k *Keyed) keyOptionsLocked(sh *keyedShard, key string) []Option {
+	opts := make([]Option, 0, len(k.defaults)+8)
+	opts = append(opts, k.defaults...)
+	if k.keyOptions != nil {
+		opts = append(opts, k.keyOptions(key)...)
+	}
+
+	opts = append(opts, sh.configs[key].options()...)
+	if k.alignment != nil {
+		if every, loc := k.alignment(key); every != 0 {
+			if every < 0 || every%(24*time.Hour) != 0 || loc == nil {
+				panic("limiter: key alignment must be whole days in a location")
+			}
+			opts = append(opts, withCalendar(int(every/(24*time.Hour)), loc))
+		}
+	}
+
+	return opts
+}
+
+// AllowBatch is AllowN for many keys at once, it returns the decision per key.
+// The keys are grouped by shard, each shard is looked up in one pass and its missing keys
+// are created under a single lock, so a batch takes the lock of a shard at most twice
+// instead of once per key. Every key is decided on its own, a denied key doesn't affect the others.
+// All keys are denied after Close.
+func (k *Keyed) AllowBatch(batch map[string]Limit) map[string]bool {
+	result := make(map[string]bool, len(batch))
+	if k.sketch != nil {
+		closed := k.closed()
+		for key, n := range batch {
+			result[key] = !closed && k.sketch.allow(key, n)
+		}
+
+		return result
+	}
+
+	entries := make(map[string]*keyedEntry, len(batch))
+	now := keyedNow()
+
+	for sh, keys := range k.byShard(batchKeys(batch)) {
+		sh.mu.RLock()
+		closed := sh.closed
+		missing := 0
+		for _, key := range keys {
+			if e, ok := sh.keys[key]; ok {
+				atomic.AddInt32(&e.active, 1)
+				atomic.StoreInt64(&e.lastUsed, now)
+				entries[key] = e
+			} else {
+				missing++
+			}
+		}
+		sh.mu.RUnlock()
+
+		if closed || missing == 0 {
+			continue
+		}
+
+		sh.mu.Lock()
+		for _, key := range keys {
+			if _, ok := entries[key]; ok || sh.closed {
+				continue
+			}
+			e := k.entryLocked(sh, key, now)
+			atomic.AddInt32(&e.active, 1)
+			entries[key] = e
+		}
+		sh.mu.Unlock()
+	}
+
+	for key, n := range batch {
+		e, ok := entries[key]
+		if !ok {
+			result[key] = false
+			continue
+		}
+
+		result[key] = e.l.AllowN(n)
+		atomic.AddInt32(&e.active, -1)
+	}
+
+	return result
+}
+
+// Preload creates the limiters of keys in advance, e.g. for hot keys at startup.
+// The options are applied after the defaults and the key options. Existing keys are kept
+// as they are. Preloaded keys are evicted like the others when they stay idle.
+// It does nothing after Close and with WithApproximateKeys.
+func (k *Keyed) Preload(keys []string, opts ...Option) {
+	if k.sketch != nil {
+		return
+	}
+
+	now := keyedNow()
+
+	for sh, keys := range k.byShard(keys) {
+		sh.mu.Lock()
+		if !sh.closed {
+			for _, key := range keys {
+				k.entryLocked(sh, key, now, opts...)
+			}
+		}
+		sh.mu.Unlock()
+	}
+}
+
+// byShard splits keys by their shards.
+func (k *Keyed) byShard(keys []string) map[*keyedShard][]string {
+	groups := make(map[*keyedShard][]string)
+	for _, key := range keys {
+		sh := k.shard(key)
+		groups[sh] = append(groups[sh], key)
+	}
+
+	return groups
+}
+
+// batchKeys returns the keys of the batch.
+func batchKeys(batch map[string]Limit) []string {
+	keys := make([]string, 0, len(batch))
+	for key := range batch {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func (k *Keyed) evictIdle() {
+	ticker := time.NewTicker(k.idle / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			k.evict()
+		case <-k.done:
+			return
+		}
+	}
+}
+
+// evict removes keys unused for the idle timeout which have nothing to keep, blocked keys are kept.
+// The shards are locked one at a time, so the calls with the other shards go on meanwhile.
+func (k *Keyed) evict() {
+	now := keyedNow()
+
+	for i := range k.shards {
+		sh := &k.shards[i]
+		sh.mu.Lock()
+		for key, e := range sh.keys {
+			if now-atomic.LoadInt64(&e.lastUsed) < int64(k.idle) || atomic.LoadInt32(&e.active) > 0 {
+				continue
+			}
+			if e.l.Used() == 0 && e.l.Waiters() == 0 && e.l.blockedAt().IsZero() {
+				delete(sh.keys, key)
+				// The key limiter leaves its shared schedule if it has one.
+				e.l.Close()
+			}
+		}
+		sh.mu.Unlock()
+	}
+}
+
+// keyedEpoch is the monotonic reference of keyedEntry.lastUsed.
+var keyedEpoch = time.Now()
+
+func keyedNow() int64 {
+	return int64(time.Since(keyedEpoch))
+}
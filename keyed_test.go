@@ -0,0 +1,114 @@
+package limiter
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeyedCloseReleasesWaiters(t *testing.T) {
+	clock := newFakeClock()
+	k := NewKeyed(WithKeyDefaults(WithRate(1, time.Hour), WithClock(clock)))
+
+	k.Allow("a")
+	done := make(chan bool)
+	for i := 0; i < 3; i++ {
+		go func() { done <- k.Wait(context.Background(), "a") }()
+	}
+	l := k.Get("a")
+	eventually(t, func() bool { return l.Waiters() == 3 })
+
+	k.Close()
+	for i := 0; i < 3; i++ {
+		select {
+		case ok := <-done:
+			if ok {
+				t.Fatal("a released waiter was granted")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("a waiter is still blocked after Close")
+		}
+	}
+
+	if k.Wait(context.Background(), "a") || k.Allow("b") || k.Get("c") != nil {
+		t.Fatal("used after Close")
+	}
+	if k.Len() != 0 {
+		t.Fatalf("%d keys after Close", k.Len())
+	}
+}
+
+func TestKeyedShards(t *testing.T) {
+	clock := newFakeClock()
+	k := NewKeyed(WithKeyDefaults(WithRate(2, time.Hour), WithClock(clock)))
+	defer k.Close()
+
+	keys := make([]string, 200)
+	shards := make(map[*keyedShard]bool)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+		shards[k.shard(keys[i])] = true
+	}
+	if len(shards) != keyedShards {
+		t.Fatalf("the keys are in %d shards, want %d", len(shards), keyedShards)
+	}
+
+	// Preloaded options are kept, an existing key isn't recreated.
+	k.Allow(keys[0])
+	k.Preload(keys[:100], WithRate(3, time.Hour))
+	if got := k.Len(); got != 100 {
+		t.Fatalf("%d keys after Preload, want 100", got)
+	}
+	if got := k.Get(keys[0]).Limit(); got != 2 {
+		t.Fatalf("the existing key has limit %d, want 2", got)
+	}
+	if got := k.Get(keys[1]).Limit(); got != 3 {
+		t.Fatalf("the preloaded key has limit %d, want 3", got)
+	}
+
+	batch := make(map[string]Limit, len(keys))
+	for _, key := range keys {
+		batch[key] = 2
+	}
+	got := k.AllowBatch(batch)
+	for i, key := range keys {
+		// The first key has a unit left only.
+		if want := i != 0; got[key] != want {
+			t.Fatalf("key %s is %t, want %t", key, got[key], want)
+		}
+	}
+	if got := k.Len(); got != len(keys) {
+		t.Fatalf("%d keys after AllowBatch, want %d", got, len(keys))
+	}
+
+	var wg sync.WaitGroup
+	var granted atomic.Int64
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, key := range keys {
+				if k.Allow(key) {
+					granted.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	// Only the first key and the preloaded keys have units left.
+	if got := granted.Load(); got != 100 {
+		t.Fatalf("granted %d concurrently, want 100", got)
+	}
+
+	k.Close()
+	if got := k.AllowBatch(batch); len(got) != len(keys) || got[keys[1]] {
+		t.Fatal("AllowBatch allowed after Close")
+	}
+	k.Preload(keys)
+	if k.Len() != 0 {
+		t.Fatal("Preload created keys after Close")
+	}
+}
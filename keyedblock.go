@@ -0,0 +1,75 @@
+package limiter
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Block denies every call with the key for d from now regardless of its usage,
+// e.g. to quarantine an abusive client. The denied calls are counted in Stats.Blocked
+// of the key limiter. The block outlives the window resets, the key isn't evicted
+// until it ends. Blocking a blocked key sets the new end. It does nothing after Close
+// and with WithApproximateKeys.
+func (k *Keyed) Block(key string, d time.Duration) {
+	if k.sketch != nil {
+		return
+	}
+
+	e := k.acquire(key)
+	if e == nil {
+		return
+	}
+	defer atomic.AddInt32(&e.active, -1)
+
+	e.l.block(e.l.now().Add(d))
+}
+
+// Unblock lifts the block of the key, see Block.
+func (k *Keyed) Unblock(key string) {
+	if e := k.lookup(key); e != nil {
+		e.l.block(time.Time{})
+	}
+}
+
+// Blocked reports whether the key is blocked and when the block ends.
+func (k *Keyed) Blocked(key string) (bool, time.Time) {
+	e := k.lookup(key)
+	if e == nil {
+		return false, time.Time{}
+	}
+
+	until := e.l.blockedAt()
+	if until.IsZero() {
+		return false, time.Time{}
+	}
+
+	return true, until
+}
+
+// lookup returns the entry of the key without creating it, nil if there is none.
+func (k *Keyed) lookup(key string) *keyedEntry {
+	sh := k.shard(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	return sh.keys[key]
+}
+
+// block sets the end of the block, the zero time lifts it.
+func (l *Limiter) block(until time.Time) {
+	var nanos int64
+	if !until.IsZero() {
+		nanos = until.UnixNano()
+	}
+	atomic.StoreInt64(&l.blockedUntil, nanos)
+}
+
+// blockedAt returns when the block ends, the zero time if the limiter isn't blocked now.
+func (l *Limiter) blockedAt() time.Time {
+	until := atomic.LoadInt64(&l.blockedUntil)
+	if until == 0 || l.now().UnixNano() >= until {
+		return time.Time{}
+	}
+
+	return time.Unix(0, until)
+}
@@ -0,0 +1,105 @@
+package limiter
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestKeyedBlock(t *testing.T) {
+	clock := newFakeClock()
+	k := NewKeyed(WithKeyDefaults(WithRate(10, time.Minute), WithClock(clock)))
+	defer k.Close()
+
+	k.Allow("a")
+	k.Block("a", 90*time.Second)
+	if blocked, until := k.Blocked("a"); !blocked || !until.Equal(clock.Now().Add(90*time.Second)) {
+		t.Fatalf("blocked %t until %v, want until in 90s", blocked, until)
+	}
+	if k.Allow("a") || k.Wait(context.Background(), "a") {
+		t.Fatal("allowed a blocked key")
+	}
+
+	// The block outlives the window reset and is counted apart from the usage.
+	clock.Advance(time.Minute)
+	if k.Allow("a") {
+		t.Fatal("allowed a blocked key after the reset")
+	}
+	if s := k.Get("a").Stats(); s.Blocked != 3 || s.Denied != 0 || s.Filtered != 0 || s.Allowed != 1 {
+		t.Fatalf("stats %+v, want 3 blocked calls and 1 allowed", s)
+	}
+
+	// Blocking again sets the new end.
+	k.Block("a", 10*time.Second)
+	clock.Advance(10 * time.Second)
+	if blocked, _ := k.Blocked("a"); blocked || !k.Allow("a") {
+		t.Fatal("the key is blocked after the new end")
+	}
+
+	k.Block("b", time.Hour)
+	k.Unblock("b")
+	if blocked, _ := k.Blocked("b"); blocked || !k.Allow("b") {
+		t.Fatal("the key is blocked after Unblock")
+	}
+	if blocked, _ := k.Blocked("missing"); blocked || k.Len() != 2 {
+		t.Fatalf("an unknown key is blocked or created, %d keys", k.Len())
+	}
+}
+
+func TestKeyedBlockEviction(t *testing.T) {
+	clock := newFakeClock()
+	k := NewKeyed(WithKeyDefaults(WithRate(10, time.Minute), WithClock(clock)), WithIdleTimeout(time.Millisecond))
+	defer k.Close()
+
+	k.Block("a", time.Minute)
+	k.Get("b")
+	time.Sleep(2 * time.Millisecond)
+	k.evict()
+	if blocked, _ := k.Blocked("a"); !blocked || k.Len() != 1 {
+		t.Fatalf("blocked %t with %d keys after the eviction, want the blocked key only", blocked, k.Len())
+	}
+
+	clock.Advance(time.Minute)
+	k.evict()
+	if n := k.Len(); n != 0 {
+		t.Fatalf("%d keys after the block ended, want 0", n)
+	}
+}
+
+func TestKeyedBlockJSON(t *testing.T) {
+	clock := newFakeClock()
+	newKeyed := func() *Keyed {
+		return NewKeyed(WithKeyDefaults(WithRate(10, time.Minute), WithClock(clock)))
+	}
+
+	src := newKeyed()
+	defer src.Close()
+	src.Block("a", 2*time.Minute)
+	src.Block("b", time.Minute)
+	src.Block("gone", time.Second)
+	clock.Advance(time.Second)
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The block outlives the usage window, the later of two blocks is kept.
+	clock.Advance(time.Minute)
+	dst := newKeyed()
+	defer dst.Close()
+	dst.Block("b", time.Hour)
+	if err := json.Unmarshal(data, dst); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if blocked, until := dst.Blocked("a"); !blocked || !until.Equal(clock.Now().Add(59*time.Second)) {
+		t.Fatalf("a blocked %t until %v, want until in 59s", blocked, until)
+	}
+	if blocked, until := dst.Blocked("b"); !blocked || !until.Equal(clock.Now().Add(time.Hour)) {
+		t.Fatalf("b blocked %t until %v, want the later block", blocked, until)
+	}
+	if n := dst.Len(); n != 2 {
+		t.Fatalf("%d keys after the import, want 2", n)
+	}
+}
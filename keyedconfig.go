@@ -0,0 +1,114 @@
+package limiter
+
+import "time"
+
+// KeyConfig is the limit of a key in Keyed, see Keyed.SetKeyConfig.
+// Zero fields are taken from the defaults and the key options of the Keyed.
+type KeyConfig struct {
+	// Limit is the budget of a window.
+	Limit Limit
+	// Interval is the length of a window.
+	Interval time.Duration
+	// Burst caps the units carried into the next window, see WithCarryOver.
+	Burst Limit
+	// GradualRecovery switches gradual recovery on or off, see WithGradualRecovery.
+	GradualRecovery *bool
+}
+
+// options returns the options which apply c after the defaults.
+func (c KeyConfig) options() []Option {
+	var opts []Option
+	if c.Limit != 0 {
+		opts = append(opts, WithMaxLimit(c.Limit))
+	}
+	if c.Interval != 0 {
+		opts = append(opts, WithInterval(c.Interval))
+	}
+	if c.Burst != 0 {
+		opts = append(opts, WithCarryOver(c.Burst))
+	}
+	if c.GradualRecovery != nil {
+		enabled := *c.GradualRecovery
+		opts = append(opts, func(l *Limiter) { l.gradualRecovery = enabled })
+	}
+
+	return opts
+}
+
+// SetKeyConfig sets the limit of the key, it is kept when the key is evicted and created again.
+// A live key limiter is changed at once under a single lock as SetLimit, SetInterval
+// and SetGradualRecovery would change it: the window keeps its start and usage.
+// The zero config returns the key to the defaults.
+// It panics if the interval is negative, and does nothing after Close.
+func (k *Keyed) SetKeyConfig(key string, cfg KeyConfig) {
+	if cfg.Interval < 0 {
+		panic("limiter: interval must be positive")
+	}
+
+	sh := k.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if sh.closed {
+		return
+	}
+
+	if cfg == (KeyConfig{}) {
+		delete(sh.configs, key)
+	} else {
+		sh.configs[key] = cfg
+	}
+
+	if e, ok := sh.keys[key]; ok {
+		// The limiter takes the values it would have if it were created now.
+		var want Limiter
+		want.limit, want.interval = Infinite, defaultInterval
+		for _, opt := range k.keyOptionsLocked(sh, key) {
+			opt(&want)
+		}
+		e.l.configure(want.limit, want.interval, want.maxCarried, want.gradualRecovery)
+	}
+}
+
+// KeyConfigOf returns the config set for the key, false if there is none.
+func (k *Keyed) KeyConfigOf(key string) (KeyConfig, bool) {
+	sh := k.shard(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	cfg, ok := sh.configs[key]
+
+	return cfg, ok
+}
+
+// configure changes the limit, the interval, the carry-over cap and gradual recovery at once.
+// Aligned windows keep their interval and fixed windows.
+func (l *Limiter) configure(limit Limit, interval time.Duration, maxCarried Limit, gradual bool) {
+	now := l.lock()
+	if l.log != nil && (limit == Infinite || limit > Limit(l.logEntries)) {
+		l.mu.Unlock()
+		panic("limiter: sliding log requires a limit not greater than max entries")
+	}
+
+	if interval != l.interval && l.group == nil && l.calendar == nil {
+		l.interval = interval
+		l.restartRecoveryLocked(now)
+		l.advanceLocked(now)
+	}
+	if gradual != l.gradualRecovery && l.calendar == nil {
+		l.gradualRecovery = gradual
+		if gradual {
+			l.restartRecoveryLocked(now)
+		}
+	}
+	l.maxCarried = maxCarried
+	if l.carried > maxCarried {
+		l.carried = maxCarried
+	}
+	if limit != l.limit {
+		l.setLimitLocked(limit, now)
+	}
+	l.unlock()
+
+	l.wakeCleanup()
+}
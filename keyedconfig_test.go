@@ -0,0 +1,75 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetKeyConfig(t *testing.T) {
+	clock := newFakeClock()
+	k := NewKeyed(WithKeyDefaults(WithRate(10, time.Minute), WithClock(clock)))
+	defer k.Close()
+
+	for i := 0; i < 4; i++ {
+		k.Allow("a")
+	}
+	l := k.Get("a")
+
+	// A partial config keeps the default interval, the window keeps its usage.
+	k.SetKeyConfig("a", KeyConfig{Limit: 5})
+	if cfg, ok := k.KeyConfigOf("a"); !ok || cfg != (KeyConfig{Limit: 5}) {
+		t.Fatalf("config %+v, %t, want the set one", cfg, ok)
+	}
+	if l.Limit() != 5 || l.Interval() != time.Minute || l.Remaining() != 1 {
+		t.Fatalf("limit %d, interval %v and remaining %d, want 5, a minute and 1", l.Limit(), l.Interval(), l.Remaining())
+	}
+
+	// A longer interval moves the end of the current window like SetInterval.
+	clock.Advance(30 * time.Second)
+	k.SetKeyConfig("a", KeyConfig{Limit: 5, Interval: 2 * time.Minute})
+	clock.Advance(time.Minute)
+	if used := l.Used(); used != 4 {
+		t.Fatalf("used %d before the longer window ends, want 4", used)
+	}
+
+	// Back to the default interval the window has ended, it resets at once.
+	gradual := true
+	k.SetKeyConfig("a", KeyConfig{Limit: 5, GradualRecovery: &gradual})
+	if used := l.Used(); used != 0 || l.Interval() != time.Minute || !l.State().GradualRecovery {
+		t.Fatalf("used %d, interval %v and gradual %t, want 0, a minute and on", used, l.Interval(), l.State().GradualRecovery)
+	}
+
+	// The zero config returns to the defaults.
+	k.SetKeyConfig("a", KeyConfig{})
+	if _, ok := k.KeyConfigOf("a"); ok {
+		t.Fatal("the zero config is kept")
+	}
+	if l.Limit() != 10 || l.State().GradualRecovery {
+		t.Fatalf("limit %d and gradual %t, want the defaults", l.Limit(), l.State().GradualRecovery)
+	}
+}
+
+func TestSetKeyConfigBeforeCreate(t *testing.T) {
+	clock := newFakeClock()
+	k := NewKeyed(WithKeyDefaults(WithRate(10, time.Minute), WithClock(clock)), WithIdleTimeout(time.Millisecond))
+	defer k.Close()
+
+	k.SetKeyConfig("b", KeyConfig{Limit: 3, Burst: 2})
+	for i := 0; i < 4; i++ {
+		k.Allow("b")
+	}
+	if used := k.Get("b").Used(); used != 3 {
+		t.Fatalf("used %d of a configured key, want 3", used)
+	}
+
+	// An evicted key is created with its config again.
+	clock.Advance(time.Minute)
+	time.Sleep(2 * time.Millisecond)
+	k.evict()
+	if n := k.Len(); n != 0 {
+		t.Fatalf("%d keys after the eviction, want 0", n)
+	}
+	if limit := k.Get("b").Limit(); limit != 3 {
+		t.Fatalf("limit %d of the recreated key, want 3", limit)
+	}
+}
@@ -0,0 +1,100 @@
+package limiter
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// KeyState is the persisted usage of a key, see Keyed.MarshalJSON.
+type KeyState struct {
+	Used        uint64    `json:"used"`
+	WindowStart time.Time `json:"windowStart"`
+	// BlockedUntil is the end of the block of the key, see Keyed.Block.
+	BlockedUntil *time.Time `json:"blockedUntil,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, it returns the usage and the block of every key as
+// an object of KeyState by key. Keys with no usage and no block are omitted.
+// The shards are read one at a time, so calls with the other keys go on meanwhile
+// and the snapshot is consistent per shard only.
+func (k *Keyed) MarshalJSON() ([]byte, error) {
+	keys := make(map[string]KeyState)
+	for i := range k.shards {
+		sh := &k.shards[i]
+		sh.mu.RLock()
+		for key, e := range sh.keys {
+			s := e.l.State()
+			ks := KeyState{Used: uint64(s.Used), WindowStart: s.WindowStart}
+			if until := e.l.blockedAt(); !until.IsZero() {
+				ks.BlockedUntil = &until
+			}
+			if ks.Used > 0 || ks.BlockedUntil != nil {
+				keys[key] = ks
+			}
+		}
+		sh.mu.RUnlock()
+	}
+
+	return json.Marshal(keys)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, it merges the output of MarshalJSON
+// into a running Keyed, e.g. after a restart. A key whose window and block have ended is skipped,
+// an existing key keeps the greater of the two usages, its own window and the later block.
+// It does nothing with WithApproximateKeys and returns ErrClosed after Close.
+func (k *Keyed) UnmarshalJSON(data []byte) error {
+	if k.sketch != nil {
+		return nil
+	}
+
+	var states map[string]KeyState
+	if err := json.Unmarshal(data, &states); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(states))
+	for key := range states {
+		keys = append(keys, key)
+	}
+
+	now := keyedNow()
+	for sh, keys := range k.byShard(keys) {
+		sh.mu.Lock()
+		if sh.closed {
+			sh.mu.Unlock()
+			return ErrClosed
+		}
+		for _, key := range keys {
+			s := states[key]
+			e, ok := sh.keys[key]
+			if ok {
+				e.l.mergeUsage(Limit(s.Used), s.WindowStart)
+			} else {
+				e = k.entryLocked(sh, key, now, WithState(State{Used: Limit(s.Used), WindowStart: s.WindowStart}))
+			}
+			if s.BlockedUntil != nil && s.BlockedUntil.After(e.l.blockedAt()) {
+				e.l.block(*s.BlockedUntil)
+			}
+
+			if !ok && e.l.Used() == 0 && e.l.blockedAt().IsZero() {
+				// The window and the block have ended, the key starts from scratch anyway.
+				delete(sh.keys, key)
+				e.l.Close()
+			}
+		}
+		sh.mu.Unlock()
+	}
+
+	return nil
+}
+
+// mergeUsage raises the usage to used if the window which started at windowStart hasn't ended.
+func (l *Limiter) mergeUsage(used Limit, windowStart time.Time) {
+	now := l.lock()
+	defer l.unlock()
+
+	if !now.Before(windowStart.Add(l.interval)) || used <= l.current {
+		return
+	}
+	l.holdLocked(used-l.current, now)
+}
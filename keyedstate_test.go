@@ -0,0 +1,91 @@
+package limiter
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKeyedJSON(t *testing.T) {
+	clock := newFakeClock()
+	newKeyed := func() *Keyed {
+		return NewKeyed(WithKeyDefaults(WithRate(10, time.Minute), WithClock(clock)))
+	}
+
+	src := newKeyed()
+	defer src.Close()
+	for i := 0; i < 3; i++ {
+		src.Allow("live")
+	}
+	src.Allow("merged")
+	src.Get("idle")
+	clock.Advance(30 * time.Second)
+	src.Allow("late")
+
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var states map[string]KeyState
+	if err := json.Unmarshal(data, &states); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(states) != 3 || states["live"].Used != 3 || !states["late"].WindowStart.Equal(src.Get("late").State().WindowStart) {
+		t.Fatalf("exported %v, want the used keys with their windows", states)
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		dst := newKeyed()
+		defer dst.Close()
+
+		// An existing key keeps the greater usage.
+		for i := 0; i < 5; i++ {
+			dst.Allow("merged")
+		}
+		if err := json.Unmarshal(data, dst); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for key, want := range map[string]Limit{"live": 3, "late": 1, "merged": 5} {
+			if got := dst.Get(key).Used(); got != want {
+				t.Fatalf("%s used %d after the import, want %d", key, got, want)
+			}
+		}
+		if n := dst.Len(); n != 3 {
+			t.Fatalf("%d keys after the import, want 3", n)
+		}
+
+		// The imported window resets when the exported one would.
+		clock.Advance(30 * time.Second)
+		if got := dst.Get("live").Used(); got != 0 {
+			t.Fatalf("live used %d after its window, want 0", got)
+		}
+		if got := dst.Get("late").Used(); got != 1 {
+			t.Fatalf("late used %d before its window ends, want 1", got)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		// Both windows have ended by now.
+		clock.Advance(time.Minute)
+		dst := newKeyed()
+		defer dst.Close()
+
+		if err := json.Unmarshal(data, dst); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n := dst.Len(); n != 0 {
+			t.Fatalf("%d keys imported from ended windows, want 0", n)
+		}
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		dst := newKeyed()
+		dst.Close()
+
+		if err := dst.UnmarshalJSON(data); !errors.Is(err, ErrClosed) {
+			t.Fatalf("got error %v, want %v", err, ErrClosed)
+		}
+	})
+}
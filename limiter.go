@@ -3,143 +3,1101 @@ package limiter
 import (
 	"context"
 	"math"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Limit defines the maximum frequency of some events.
-// Limit is represented as a number of events per second.
+// Limit is represented as a number of events per interval,
+// the interval is set by WithInterval or WithRate.
 type Limit uint64
 
 // Infinite is the infinite rate limit; it allows all events.
 // If you don't send the option WithMaxLimit, the limiter will use an infinite limit.
 const Infinite Limit = math.MaxUint64
 
-// If you don't send option the WithInterval, the limiter will use this interval.
+// If you don't send option the WithInterval or WithRate, the limiter will use this interval.
 const defaultInterval = time.Hour
 
+// lastID is the id of the latest created limiter.
+var lastID uint64
+
 // Limiter responsible for managing allows requests.
+// It must be created with New, methods of the zero value panic.
 type Limiter struct {
-	mu       sync.RWMutex
+	mu sync.Mutex
+	// id orders limiters for AllowAll and WaitAll.
+	id       uint64
+	name     string
 	limit    Limit
 	current  Limit
+	reserved Limit
 	interval time.Duration
 
 	gradualRecovery bool
+	// refill is the units restored per interval in token bucket mode, the limit is the burst.
+	refill Limit
+	// slidingWindow counts the previous window, weighted by its overlap with the trailing interval.
+	slidingWindow bool
+	// previous is the usage of the previous window in sliding window mode.
+	previous Limit
+
+	// log is used instead of interval resets in sliding log mode.
+	log        *slidingLog
+	logEntries int
+
+	overage OverageMode
+	// carried is the unused part of the previous windows added to the limit, up to maxCarried.
+	carried    Limit
+	maxCarried Limit
+	// maxDebt is how far a cost may take the usage above the budget, see WithOverdraft.
+	maxDebt Limit
+
+	shadow  bool
+	stats   Stats
+	onDeny  func(DenyInfo)
+	onEvent func(Event)
+	onReset func()
+
+	meter *rateMeter
+	// rateHorizon is only used to build meter.
+	rateHorizon time.Duration
+
+	policy Policy
+	random func() float64
 
-	done chan struct{}
+	thresholds      []threshold
+	burn            *burnAlert
+	history         *history
+	observed        observed
+	filters         []func() bool
+	filterRequeue   bool
+	rearmThresholds bool
+	// hooks are callbacks collected under the lock, they are run by unlock.
+	hooks []func()
+
+	waiters    waitQueue
+	maxWaiters int
+
+	// lastNow is the latest time the state was advanced to.
+	lastNow time.Time
+	// windowID counts the interval boundaries passed since New.
+	windowID uint64
+	// windowStart is the beginning of the current interval.
+	windowStart time.Time
+	// nextStep is the time of the next gradual recovery step.
+	nextStep time.Time
+	// recoveryOrigin is the start of the current recovery period
+	// and recovered is the number of units restored since it.
+	recoveryOrigin time.Time
+	recovered      Limit
+	// smoothing spaces grants evenly, nextGrant is when the next one is allowed.
+	smoothing bool
+	nextGrant time.Time
+	// adaptive adjusts the limit after every window, see WithAdaptive.
+	adaptive *adaptive
+	// warmup is how long the limit ramps up after warmStart, see WithWarmup.
+	warmup    time.Duration
+	warmStart time.Time
+	// recoveryStep units are restored every recoveryEvery, see WithGradualRecovery.
+	recoveryStep  Limit
+	recoveryEvery time.Duration
+	// group drives window resets instead of the cleanup goroutine.
+	group *ResetGroup
+	// calendar aligns the windows to local days, see WithKeyAlignment.
+	calendar *calendar
+	// quotas are additional windows which must allow an event too.
+	quotas []quota
+
+	// lazy limiter has no cleanup goroutine, resets are applied on access.
+	lazy       bool
+	reschedule chan struct{}
+	closed     bool
+	// external limiter is refilled by AddCapacity only.
+	external bool
+	// movedTo is the limiter the state was transferred to.
+	movedTo *Limiter
+	// blockedUntil is when the block of the key ends in Unix nanoseconds of the clock,
+	// zero if it isn't blocked, see Keyed.Block.
+	blockedUntil int64
+	strict       bool
+	clock        Clock
+	done         chan struct{}
+	// released fails the waiters when it is closed, Keyed closes it for its key limiters.
+	released <-chan struct{}
+	// stopped is closed when the cleanup goroutine returns.
+	stopped chan struct{}
+}
+
+// NewWithContext is the same as New, but the limiter is closed when ctx is done.
+// Calling Close earlier is still allowed.
+func NewWithContext(ctx context.Context, opts ...Option) *Limiter {
+	l := New(opts...)
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				l.Close()
+			case <-l.done:
+			}
+		}()
+	}
+
+	return l
 }
 
 // New build and returns new instance Limiter.
-func New(opts ...func(*Limiter)) *Limiter {
+// It panics if the configured interval is not positive or the reserved part is greater than the limit.
+// The limiter blocks only on channels and timers, so it can run inside a testing/synctest bubble
+// if it is created and closed there.
+func New(opts ...Option) *Limiter {
 	l := &Limiter{
-		mu:              sync.RWMutex{},
+		mu:              sync.Mutex{},
 		limit:           Infinite,
 		current:         0,
 		interval:        defaultInterval,
+		rateHorizon:     defaultRateHorizon,
 		gradualRecovery: false,
+		random:          rand.Float64,
+		clock:           systemClock{},
+		maxWaiters:      -1,
+		reschedule:      make(chan struct{}, 1),
 		done:            make(chan struct{}, 1),
+		stopped:         make(chan struct{}),
 	}
 
 	for i := range opts {
 		opts[i](l)
 	}
 
-	go l.cleanupLimitAfterInterval()
+	if l.interval <= 0 {
+		panic("limiter: interval must be positive")
+	}
+	if l.rateHorizon <= 0 {
+		panic("limiter: rate horizon must be positive")
+	}
+	l.meter = newRateMeter(l.rateHorizon, l.now())
+	if l.reserved > l.limit {
+		panic("limiter: reserved is greater than limit")
+	}
+	if l.logEntries != 0 {
+		if l.limit == Infinite || l.limit > Limit(l.logEntries) {
+			panic("limiter: sliding log requires a limit not greater than max entries")
+		}
+		l.log = newSlidingLog(int(l.limit))
+	}
+	if l.adaptive != nil {
+		if l.log != nil && l.adaptive.max > Limit(l.logEntries) {
+			panic("limiter: sliding log requires a limit not greater than max entries")
+		}
+		if l.limit < l.adaptive.min || l.limit > l.adaptive.max {
+			l.limit = l.adaptive.max
+		}
+	}
+	if l.group != nil && l.log != nil {
+		panic("limiter: sliding log can't use a shared schedule")
+	}
+	if l.group != nil && !sameClock(l.group.clock, l.clock) {
+		panic("limiter: shared schedule must use the clock of the limiter")
+	}
+	// The members see the time of the schedule, even if the clock is stepped back.
+	if l.group != nil {
+		l.clock = l.group.clock
+	} else {
+		l.clock = steady(l.clock)
+	}
+	if l.external {
+		if l.log != nil {
+			panic("limiter: sliding log can't use external refill")
+		}
+		l.current = l.limit
+	}
+
+	now := l.now()
+	if l.windowStart.IsZero() {
+		l.windowStart = now
+	} else {
+		// Keep the monotonic clock reading, so stepping the wall clock doesn't move the windows.
+		l.windowStart = now.Add(l.windowStart.Sub(now))
+	}
+	if l.calendar != nil {
+		if l.log != nil || l.gradualRecovery || l.group != nil || l.external {
+			panic("limiter: key alignment requires fixed windows")
+		}
+		l.windowStart, l.interval = l.calendar.window(l.windowStart)
+	}
+	l.observed.base = now
+	l.warmStart = now
+	l.id = atomic.AddUint64(&lastID, 1)
+	l.restartRecoveryLocked(l.windowStart)
+	for i := range l.quotas {
+		l.quotas[i].start = l.windowStart
+	}
+
+	if l.log != nil {
+		if l.current > l.limit {
+			l.current = l.limit
+		}
+		for i := Limit(0); i < l.current; i++ {
+			l.log.push(l.windowStart)
+		}
+	}
+
+	if l.group != nil {
+		l.group.join(l)
+	}
+
+	l.mu.Lock()
+	if !l.lazy && l.group == nil && l.current > 0 {
+		// A window restored late gets the resets and recovery steps due since its start
+		// before the first call, as a continuously running limiter would have.
+		restored := l.current
+		l.advanceLocked(now)
+		if l.current < restored {
+			l.stats.CaughtUp = restored - l.current
+		}
+	}
+	l.publishLocked()
+	l.unlock()
+
+	if !l.lazy {
+		go l.cleanupLimitAfterInterval()
+	}
 
 	return l
 }
 
+// checkNew panics if the limiter wasn't created by New, the zero value isn't usable.
+func (l *Limiter) checkNew() {
+	if l.done == nil {
+		panic("limiter: Limiter must be created with New")
+	}
+}
+
 // Allow checks available for calling requests.
-// If it calls in the first time
-// will start goroutine to nullify the current limit after interval.
+// The reserved part of the limit isn't available for Allow, see WithReserved.
+// What happens when the limit is saturated depends on the policy, see WithDenyPolicy.
 func (l *Limiter) Allow() bool {
+	l.checkNew()
+	return !l.filtered() && l.allow("Allow", 1)
+}
+
+// AllowN is the same as Allow, but consumes n units at once or nothing.
+func (l *Limiter) AllowN(n Limit) bool {
+	l.checkNew()
+	return !l.filtered() && l.allow("AllowN", n)
+}
+
+// allow is the same as AllowN without the filters.
+func (l *Limiter) allow(method string, n Limit) bool {
 	l.mu.Lock()
-	defer l.mu.Unlock()
+	l.checkStrictLocked(method)
+	now := l.now()
+	if l.policy.kind == policyDelay && !l.shadow {
+		allow := l.takeLocked(n, l.reserved, now)
+		l.unlock()
 
-	var allow bool
-	switch {
-	case l.current < l.limit:
-		allow = true
+		return allow || l.allowDelayed(n)
+	}
+
+	allow := l.allowLocked(n, l.reserved, true, now)
+	l.unlock()
+
+	return allow
+}
+
+// AllowAt is the same as Allow, but decides as if the current time were t.
+// It is meant for replaying recorded traffic through a limiter with WithLazyReset,
+// where no goroutine advances the state with the wall clock.
+// The delay policy is not applied. It panics if t is before the time of a previous call.
+func (l *Limiter) AllowAt(t time.Time) bool {
+	return l.AllowNAt(t, 1)
+}
+
+// AllowNAt is the same as AllowAt, but consumes n units at once or nothing.
+func (l *Limiter) AllowNAt(t time.Time, n Limit) bool {
+	l.checkNew()
+	l.mu.Lock()
+	if t.Before(l.lastNow) {
+		l.mu.Unlock()
+		panic("limiter: AllowAt time is before a previous call")
+	}
+	l.checkStrictLocked("AllowAt")
+	l.mu.Unlock()
+
+	if l.filtered() {
+		return false
+	}
+
+	l.mu.Lock()
+
+	allow := l.allowLocked(n, l.reserved, true, t)
+	l.unlock()
+
+	return allow
+}
+
+// AllowErr is the same as Allow, but returns *RateLimitError instead of false.
+func (l *Limiter) AllowErr() error {
+	l.checkNew()
+	if l.strict && l.isClosed() {
+		return ErrClosed
+	}
+
+	if l.filtered() {
+		return ErrFiltered
+	}
+
+	if l.allow("AllowErr", 1) {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.unlock()
+
+	return l.rateLimitErrorLocked(1, l.reserved, l.now())
+}
+
+// allowN is the same as AllowErr for n units, the deny policy is not applied.
+func (l *Limiter) allowN(n Limit) error {
+	if l.filtered() {
+		return ErrFiltered
+	}
+
+	l.mu.Lock()
+	defer l.unlock()
+
+	if l.strict && l.closed {
+		return ErrClosed
 	}
 
-	if allow {
-		l.current++
+	now := l.now()
+	if l.allowLocked(n, l.reserved, false, now) {
+		return nil
 	}
 
+	return l.rateLimitErrorLocked(n, l.reserved, now)
+}
+
+// rateLimitErrorLocked describes a denied request for n units.
+func (l *Limiter) rateLimitErrorLocked(n, reserved Limit, now time.Time) *RateLimitError {
+	return &RateLimitError{
+		Name:       l.name,
+		Limit:      l.budgetLocked(),
+		Used:       l.current,
+		RetryAfter: l.retryAfterLocked(n, reserved, now),
+	}
+}
+
+// AllowReserved is the same as Allow, but it can also use the reserved part of the limit.
+// Use it for priority traffic which must pass when regular traffic has consumed everything.
+// It always uses the reject policy.
+func (l *Limiter) AllowReserved() bool {
+	l.checkNew()
+	if l.filtered() {
+		return false
+	}
+
+	l.mu.Lock()
+	l.checkStrictLocked("AllowReserved")
+	allow := l.allowLocked(1, 0, false, l.now())
+	l.unlock()
+
 	return allow
 }
 
-// Current returns current limit.
+// allowLocked consumes n units if they are available without the reserved units,
+// otherwise it records the denial. With shed it also applies the shed policy.
+func (l *Limiter) allowLocked(n, reserved Limit, shed bool, now time.Time) bool {
+	l.advanceLocked(now)
+
+	if shed && l.shedLocked() {
+		l.stats.Shed++
+		return false
+	}
+	if !l.takeLocked(n, reserved, now) {
+		return l.denyLocked(n)
+	}
+
+	return true
+}
+
+// takeLocked consumes n units if they are available without the reserved units.
+func (l *Limiter) takeLocked(n, reserved Limit, now time.Time) bool {
+	l.advanceLocked(now)
+
+	if !l.availableLocked(n, reserved) {
+		return false
+	}
+
+	l.consumeLocked(n, now)
+
+	return true
+}
+
+// availableLocked checks n units are available without the reserved units.
+func (l *Limiter) availableLocked(n, reserved Limit) bool {
+	if l.movedTo != nil {
+		// The units are served by the limiter the state was transferred to.
+		return false
+	}
+
+	room, ok := l.roomLocked(l.budgetLocked(), n, reserved)
+	if !ok || l.usageLocked() > room || !l.spacedLocked() {
+		return false
+	}
+
+	for i := range l.quotas {
+		if !l.quotas[i].available(n) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// release gives back n units which were consumed but not used.
+func (l *Limiter) release(n Limit) {
+	l.mu.Lock()
+	l.releaseLocked(n)
+	l.unlock()
+}
+
+// releaseLocked is the same as release under the lock.
+func (l *Limiter) releaseLocked(n Limit) {
+	if l.current < n {
+		l.current = 0
+	} else {
+		l.current -= n
+	}
+	if l.log != nil {
+		l.log.dropNewest(int(n))
+	}
+	for i := range l.quotas {
+		q := &l.quotas[i]
+		if q.current < n {
+			q.current = 0
+		} else {
+			q.current -= n
+		}
+	}
+	l.grantWaitersLocked(l.now())
+}
+
+// consumeLocked takes n units and counts them in the stats.
+func (l *Limiter) consumeLocked(n Limit, now time.Time) {
+	l.holdLocked(n, now)
+	l.countLocked(n, now)
+}
+
+// countLocked counts n consumed units in the stats and the rate.
+func (l *Limiter) countLocked(n Limit, now time.Time) {
+	l.stats.Allowed += uint64(n)
+	l.meter.add(now, uint64(n))
+	l.eventLocked(EventAllowed, n, 0)
+}
+
+// holdLocked takes n units without counting them in the stats.
+func (l *Limiter) holdLocked(n Limit, now time.Time) {
+	if l.gradualRecovery && l.nextStep.IsZero() {
+		// The limiter was fully recovered, the steps start from now.
+		l.restartRecoveryLocked(now)
+	}
+	l.current += n
+	for i := range l.quotas {
+		if l.quotas[i].current == 0 {
+			l.wakeCleanup()
+		}
+		l.quotas[i].current += n
+	}
+	if l.log != nil {
+		if l.log.size == 0 {
+			l.wakeCleanup()
+		}
+		for i := Limit(0); i < n; i++ {
+			l.log.push(now)
+		}
+	}
+	l.spaceLocked(n, now)
+	l.checkThresholdsLocked()
+	l.checkBurnLocked(now)
+}
+
+// Peek reports whether Allow would succeed right now, without consuming anything.
+// The answer is advisory: the limit can be consumed before a subsequent Allow.
+func (l *Limiter) Peek() bool {
+	return l.PeekN(1)
+}
+
+// PeekN reports whether n units are available right now, without consuming anything.
+// Like Peek, the answer is advisory.
+func (l *Limiter) PeekN(n Limit) bool {
+	l.checkNew()
+	// The overdraft depends on the options only, so it can be checked without the lock.
+	if n > 0 && !l.overdraftLocked() {
+		if free, ok := l.loadFresh(l.now(), func(o *observed) uint64 { return atomic.LoadUint64(&o.free) }); ok {
+			return n <= Limit(free)
+		}
+	}
+
+	l.lock()
+	defer l.unlock()
+
+	return l.availableLocked(n, l.reserved)
+}
+
+// Name returns the limiter name, see WithName.
+func (l *Limiter) Name() string {
+	l.checkNew()
+	return l.name
+}
+
+// Current is Used, it is kept for compatibility.
 func (l *Limiter) Current() Limit {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
+	return l.Used()
+}
+
+// Used returns how many units are consumed in the current window.
+// It doesn't take the lock unless a reset is due.
+func (l *Limiter) Used() Limit {
+	l.checkNew()
+	if state, _, ok := l.loadObserved(l.now()); ok {
+		return state.Used
+	}
+
+	l.lock()
+	defer l.unlock()
 
 	return l.current
 }
 
+// Remaining returns how many units are left in the current window, see State.Remaining.
+// It doesn't take the lock unless a reset is due.
+func (l *Limiter) Remaining() Limit {
+	l.checkNew()
+	if state, _, ok := l.loadObserved(l.now()); ok {
+		return state.Remaining
+	}
+
+	l.lock()
+	defer l.unlock()
+
+	return l.remainingLocked()
+}
+
+// ResetAt returns when the current window ends, see State.NextReset.
+// It doesn't take the lock unless a reset is due.
+func (l *Limiter) ResetAt() time.Time {
+	return l.State().NextReset
+}
+
+// Usage returns the consumed units, the budget of the current window
+// and how long until it ends, for reporting the quota status.
+// It doesn't take the lock unless a reset is due.
+func (l *Limiter) Usage() (current, limit Limit, resetIn time.Duration) {
+	state := l.State()
+	resetIn = state.NextReset.Sub(l.now())
+	if resetIn < 0 {
+		resetIn = 0
+	}
+
+	return state.Used, state.Limit, resetIn
+}
+
+// WindowID returns the number of the current window, it starts at zero and grows by one
+// with every interval boundary, including the windows passed without access.
+// Gradual recovery steps and SetLimit don't change it. Use it to do something once per window.
+func (l *Limiter) WindowID() uint64 {
+	l.checkNew()
+	if state, _, ok := l.loadObserved(l.now()); ok {
+		return state.WindowID
+	}
+
+	l.lock()
+	defer l.unlock()
+
+	return l.windowID
+}
+
+// WindowProgress returns how long the current interval has been running and its total length.
+// In gradual recovery mode the window is still the whole interval, not a recovery step.
+func (l *Limiter) WindowProgress() (elapsed, total time.Duration) {
+	l.checkNew()
+	now := l.now()
+	offset, total, ok := l.loadWindow(now)
+	if !ok {
+		now = l.lock()
+		offset, total = l.windowStart.Sub(l.observed.base), l.interval
+		l.unlock()
+	}
+
+	elapsed = now.Sub(l.observed.base) - offset
+	switch {
+	case elapsed < 0:
+		elapsed = 0
+	case elapsed >= total:
+		elapsed %= total
+	}
+
+	return elapsed, total
+}
+
 // Wait waits when we can call Allow.
 // If ctx done, will return false.
+// In shadow mode it doesn't block. If the wait queue is full, see WithMaxWaiters,
+// or ctx deadline comes before a unit can be available, it returns false immediately.
+// Waiters are served in FIFO order.
 func (l *Limiter) Wait(ctx context.Context) bool {
-	allow := make(chan struct{}, 1)
-	go func() {
-		defer close(allow)
-
-		for {
-			l.mu.RLock()
-			if l.current < l.limit {
-				allow <- struct{}{}
-				break
-			}
-			l.mu.RUnlock()
+	l.checkNew()
+	l.checkStrictWait(ctx, "Wait")
+	_, err := l.wait(ctx, "", 1)
+	return err == nil
+}
+
+// WaitErr is the same as Wait, but returns why it failed: ctx error, ErrClosed,
+// ErrQueueFull, ErrWouldExceedDeadline, ErrExceedsLimit or ErrFiltered.
+// Use AllowErr to get the retry time of a denied call without waiting.
+func (l *Limiter) WaitErr(ctx context.Context) error {
+	l.checkNew()
+	l.checkStrictWait(ctx, "WaitErr")
+	_, err := l.wait(ctx, "", 1)
+	return err
+}
+
+// WaitPriority is the same as Wait, but a waiter with a priority above zero is served
+// before the regular waiters and the waiters with a lower priority,
+// e.g. for health checks and admin calls. Waiters with the same priority are served in FIFO order.
+func (l *Limiter) WaitPriority(ctx context.Context, priority int) bool {
+	l.checkNew()
+	l.checkStrictWait(ctx, "WaitPriority")
+	_, err := l.waitPriority(ctx, "", 1, priority)
+	return err == nil
+}
+
+// WaitKeyed is the same as Wait, but released units are shared round-robin
+// between keys with blocked waiters, so a key with many waiters
+// doesn't hold back the others. Waiters with the same key are served in FIFO order.
+// Wait uses the empty key.
+func (l *Limiter) WaitKeyed(ctx context.Context, key string) bool {
+	l.checkNew()
+	l.checkStrictWait(ctx, "WaitKeyed")
+	_, err := l.wait(ctx, key, 1)
+	return err == nil
+}
+
+// WaitN is the same as Wait, but waits for n units at once, nothing is taken if it fails.
+// It returns false immediately if n units can never be available with the current limit,
+// e.g. n is greater than the limit.
+func (l *Limiter) WaitN(ctx context.Context, n Limit) bool {
+	l.checkNew()
+	l.checkStrictWait(ctx, "WaitN")
+	_, err := l.wait(ctx, "", n)
+	return err == nil
+}
+
+// WaitDuration is the same as Wait, but also returns how long the call was blocked.
+// It is zero if a unit was available immediately. If the wait fails,
+// it is the time spent before ctx was done or the limiter was closed.
+func (l *Limiter) WaitDuration(ctx context.Context) (time.Duration, bool) {
+	l.checkNew()
+	l.checkStrictWait(ctx, "WaitDuration")
+	blocked, err := l.wait(ctx, "", 1)
+	return blocked, err == nil
+}
+
+// wait is the same as acquire, but checks the filters before queuing and after a blocked waiter
+// is granted. If they fail after the grant, the units are returned and the waiter fails
+// or queues again, see WithFilterRequeue.
+func (l *Limiter) wait(ctx context.Context, key string, n Limit) (time.Duration, error) {
+	return l.waitPriority(ctx, key, n, 0)
+}
+
+// waitPriority is wait with the priority of the waiter, see WaitPriority.
+func (l *Limiter) waitPriority(ctx context.Context, key string, n Limit, priority int) (time.Duration, error) {
+	if l.filtered() {
+		return 0, ErrFiltered
+	}
+
+	blocked, err := l.acquire(ctx, key, n, priority, true)
+	if err != nil || blocked == 0 {
+		return blocked, err
+	}
+
+	for len(l.filters) != 0 && l.filtered() {
+		l.refund(n)
+		if !l.filterRequeue {
+			return blocked, ErrFiltered
+		}
+
+		again, err := l.acquire(ctx, key, n, priority, false)
+		blocked += again
+		if err != nil {
+			return blocked, err
 		}
-	}()
+	}
+
+	l.waited(n, blocked)
+
+	return blocked, nil
+}
+
+// acquire queues the caller with the key and the priority until n units are granted
+// and returns how long it was blocked. With fast it takes free units without queuing
+// if nobody waits, otherwise it is queued behind the others and granted in their order.
+// If the units can never be available with the limit or ctx has a deadline before they can be,
+// it fails immediately.
+func (l *Limiter) acquire(ctx context.Context, key string, n Limit, priority int, fast bool) (time.Duration, error) {
+	l.mu.Lock()
+	select {
+	case <-l.done:
+		l.unlock()
+		return 0, ErrClosed
+	case <-l.released:
+		l.unlock()
+		return 0, ErrClosed
+	default:
+	}
+
+	if l.shadow {
+		l.allowLocked(n, l.reserved, false, l.now())
+		l.unlock()
+
+		return 0, nil
+	}
+
+	if fast && l.waiters.peek() == nil && l.takeLocked(n, l.reserved, l.now()) {
+		l.unlock()
+		return 0, nil
+	}
+
+	if l.maxWaiters >= 0 && l.waiters.len() >= l.maxWaiters {
+		l.unlock()
+		return 0, ErrQueueFull
+	}
+
+	start := l.now()
+	if !l.external {
+		// A zero limit can still be raised by SetLimit.
+		at, ok := l.availableAtLocked(n, l.reserved, start)
+		if !ok && l.limit != 0 {
+			l.unlock()
+			return 0, ErrExceedsLimit
+		}
+		// The deadline is in the system time, the clock of the limiter may be another one.
+		if deadline, dok := ctx.Deadline(); dok && (!ok || at.Sub(start) > time.Until(deadline)) {
+			l.unlock()
+			return 0, ErrWouldExceedDeadline
+		}
+	}
+
+	w := &waiter{key: key, n: n, priority: priority, ready: make(chan struct{})}
+	l.waiters.push(w)
+	if !fast {
+		l.grantWaitersLocked(start)
+	}
+	next := l.nextEventLocked()
+	l.unlock()
+
+	owner := l
+	for {
+		for owner.park(ctx, w, next) {
+			owner.lock()
+			next = owner.nextEventLocked()
+			owner.unlock()
+		}
+
+		select {
+		case <-w.ready:
+			return l.now().Sub(start), nil
+		default:
+		}
+
+		// The waiter is moved with the rest of the state by TransferTo.
+		parked := owner
+		owner = owner.lockOwner()
+		if w.granted {
+			owner.unlock()
+			return l.now().Sub(start), nil
+		}
+		if owner == parked || ctx.Err() != nil {
+			break
+		}
+
+		next = owner.nextEventLocked()
+		owner.unlock()
+	}
+	defer owner.unlock()
+
+	owner.waiters.remove(w)
+	owner.grantWaitersLocked(l.now())
+
+	if err := ctx.Err(); err != nil {
+		return l.now().Sub(start), err
+	}
+
+	return l.now().Sub(start), ErrClosed
+}
+
+// park blocks the waiter until it is granted, ctx is done or the limiter is closed or released.
+// It returns true if the next event is due, which only a lazy limiter has to apply.
+func (l *Limiter) park(ctx context.Context, w *waiter, next time.Time) bool {
+	var fire <-chan time.Time
+	if l.lazy && !next.IsZero() {
+		var release func()
+		fire, release = l.after(next.Sub(l.now()))
+		defer release()
+	}
 
 	select {
+	case <-w.ready:
+	case <-fire:
+		return true
 	case <-ctx.Done():
-		return false
-	case <-allow:
-		return l.Allow()
 	case <-l.done:
-		return false
+	case <-l.released:
 	}
+
+	return false
 }
 
-// Close limiter workers.
-// It must be call.
-func (l *Limiter) Close() {
+// Close limiter workers and wait until they are stopped, no reset is applied in the background after it.
+// It must be call, unless the limiter uses lazy reset where it only leaves the shared schedule.
+// If the limiter is already closed, it returns ErrClosed.
+// Before the error was added Close returned nothing, see CHANGELOG.md.
+func (l *Limiter) Close() error {
+	l.checkNew()
+	if l.external {
+		l.mu.Lock()
+		l.current = l.limit
+		l.unlock()
+	}
+
+	if l.lazy {
+		if l.group != nil {
+			l.group.leave(l)
+		}
+
+		return nil
+	}
+
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return ErrClosed
+	}
+	l.closed = true
+	l.mu.Unlock()
+
+	l.stop()
+
+	return nil
+}
+
+// Done returns a channel which is closed when the limiter is closed.
+// A lazy limiter isn't closed by Close, see WithLazyReset.
+func (l *Limiter) Done() <-chan struct{} {
+	l.checkNew()
+	return l.done
+}
+
+// stop leaves the shared schedule and stops the cleanup goroutine of a closed limiter.
+func (l *Limiter) stop() {
+	if l.group != nil {
+		l.group.leave(l)
+	}
+
 	close(l.done)
+	if !l.lazy {
+		<-l.stopped
+	}
 }
 
-func (l *Limiter) cleanupLimitAfterInterval() {
-	l.mu.RLock()
-	interval := l.interval
-	repair := l.limit
-	l.mu.RUnlock()
+// SetGradualRecovery switches gradual recovery on a running limiter.
+// Turning it off schedules a full reset at the end of the current interval,
+// turning it on starts restoring the limit step by step from now.
+func (l *Limiter) SetGradualRecovery(enabled bool) {
+	l.checkNew()
+	l.mu.Lock()
+	if l.gradualRecovery != enabled {
+		now := l.now()
+		l.advanceLocked(now)
 
-	if l.gradualRecovery {
-		x := int(interval.Seconds()) / int(repair)
-		interval = time.Second * time.Duration(x)
-		repair = 1
+		l.gradualRecovery = enabled
+		if enabled {
+			l.restartRecoveryLocked(now)
+		}
 	}
+	l.unlock()
+
+	l.wakeCleanup()
+}
+
+// lock locks the limiter and applies all resets and recovery steps due by now.
+func (l *Limiter) lock() time.Time {
+	l.mu.Lock()
+
+	now := l.now()
+	l.advanceLocked(now)
+
+	return now
+}
+
+// unlock releases the lock and runs hooks collected while it was held.
+func (l *Limiter) unlock() {
+	for _, hook := range l.unlockHooks() {
+		hook()
+	}
+}
+
+// unlockHooks is unlock which returns the hooks instead of running them,
+// so a caller holding other locks can run them after releasing those.
+func (l *Limiter) unlockHooks() []func() {
+	l.publishLocked()
+	hooks := l.hooks
+	l.hooks = nil
+	l.mu.Unlock()
+
+	return hooks
+}
+
+// wakeCleanup makes the cleanup goroutine recalculate its schedule.
+func (l *Limiter) wakeCleanup() {
+	select {
+	case l.reschedule <- struct{}{}:
+	default:
+	}
+}
+
+func (l *Limiter) cleanupLimitAfterInterval() {
+	defer close(l.stopped)
 
 	for {
+		l.mu.Lock()
+		next := l.nextEventLocked()
+		l.mu.Unlock()
+
+		var (
+			fire    <-chan time.Time
+			release = func() {}
+		)
+		if !next.IsZero() {
+			fire, release = l.after(next.Sub(l.now()))
+		}
+
 		select {
-		case <-time.After(l.interval):
+		case <-fire:
 			l.mu.Lock()
+			l.advanceLocked(l.now())
+			l.unlock()
+			continue
+		case <-l.reschedule:
+		case <-l.done:
+		}
 
-			if l.gradualRecovery {
-				l.current -= repair
-				if l.current < 0 {
-					l.current = 0
-				}
-			} else {
-				l.current = 0
-			}
+		release()
 
-			l.mu.Unlock()
+		select {
 		case <-l.done:
 			return
+		default:
+		}
+	}
+}
+
+// rolloverLocked counts the windows passed and rearms what is done once per window.
+func (l *Limiter) rolloverLocked(windows uint64) {
+	l.windowID += windows
+	l.rearmThresholdsLocked(true)
+	if l.burn != nil {
+		l.burn.fired = false
+	}
+	if l.onReset != nil {
+		l.hooks = append(l.hooks, l.onReset)
+	}
+	if l.adaptive != nil {
+		l.hooks = append(l.hooks, l.adapt)
+	}
+}
+
+// nextEventLocked returns time when the current limit should be changed.
+// The zero time means there is nothing scheduled.
+func (l *Limiter) nextEventLocked() time.Time {
+	next := earliest(l.windowEventLocked(), l.warmupEventLocked())
+	next = earliest(next, l.smoothingEventLocked())
+	for i := range l.quotas {
+		next = earliest(next, l.quotas[i].nextEvent())
+	}
+
+	return next
+}
+
+// windowEventLocked returns time when the main limit should be changed.
+func (l *Limiter) windowEventLocked() time.Time {
+	if l.log != nil {
+		oldest, ok := l.log.oldest()
+		if !ok {
+			return time.Time{}
+		}
+
+		return oldest.Add(l.interval)
+	}
+
+	switch {
+	case l.gradualRecovery && l.group == nil:
+		// Only the window ID rolls over while nothing is used.
+		return earliest(l.nextStep, l.windowStart.Add(l.interval))
+	case l.gradualRecovery:
+		return l.nextStep
+	case l.group != nil, l.external:
+		return time.Time{}
+	}
+
+	end := l.windowStart.Add(l.interval)
+	if counted := l.previousLocked(l.lastNow); counted > 0 {
+		// The counted part of the previous window drops by a unit.
+		return end.Add(-l.slideLocked(counted-1, l.previous))
+	}
+
+	return end
+}
+
+// advanceLocked applies all resets and recovery steps which are due by now.
+func (l *Limiter) advanceLocked(now time.Time) {
+	if now.After(l.lastNow) {
+		l.lastNow = now
+	}
+	if l.calendar != nil {
+		l.alignLocked(now)
+	}
+	l.recordWindowsLocked(now)
+
+	if l.external {
+		// Only AddCapacity refills the limiter.
+	} else if l.log != nil {
+		l.log.prune(now.Add(-l.interval))
+		l.current = Limit(l.log.size)
+	} else if l.gradualRecovery {
+		if !l.nextStep.IsZero() && !now.Before(l.nextStep) {
+			l.recoverLocked(now)
+
+			if l.rearmThresholds {
+				l.rearmThresholdsLocked(false)
+			}
 		}
+	} else if elapsed := now.Sub(l.windowStart); elapsed >= l.interval {
+		l.resetLocked(Limit(elapsed / l.interval))
 	}
+
+	if elapsed := now.Sub(l.windowStart); !l.external && elapsed >= l.interval {
+		l.windowStart = l.windowStart.Add(elapsed / l.interval * l.interval)
+		l.rolloverLocked(uint64(elapsed / l.interval))
+	}
+
+	for i := range l.quotas {
+		l.quotas[i].advance(now)
+	}
+
+	l.grantWaitersLocked(now)
 }
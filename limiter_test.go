@@ -0,0 +1,240 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSetGradualRecovery(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(10, 10*time.Second), WithClock(clock))
+	defer l.Close()
+
+	if got := allowed(l, 11); got != 10 {
+		t.Fatalf("allowed %d, want 10", got)
+	}
+
+	// The fixed window gives nothing back before it ends.
+	clock.Advance(3 * time.Second)
+	if l.Allow() {
+		t.Fatal("allowed in the middle of an exhausted fixed window")
+	}
+
+	// Turned on mid-window, a unit is restored every second from now.
+	l.SetGradualRecovery(true)
+	clock.Advance(time.Second)
+	if got := allowed(l, 2); got != 1 {
+		t.Fatalf("allowed %d a second after turning recovery on, want 1", got)
+	}
+	clock.Advance(2 * time.Second)
+	if got := allowed(l, 3); got != 2 {
+		t.Fatalf("allowed %d two seconds later, want 2", got)
+	}
+
+	// Turned off, nothing is restored until the full reset at the end of the interval.
+	l.SetGradualRecovery(false)
+	clock.Advance(3 * time.Second)
+	if l.Allow() {
+		t.Fatal("allowed after turning recovery off before the interval ends")
+	}
+	clock.Advance(time.Second)
+	if got := allowed(l, 11); got != 10 {
+		t.Fatalf("allowed %d after the interval ended, want 10", got)
+	}
+	if l.State().GradualRecovery {
+		t.Fatal("State reports gradual recovery after turning it off")
+	}
+}
+
+func TestWithStartTime(t *testing.T) {
+	const interval = 10 * time.Second
+
+	tests := []struct {
+		name  string
+		start time.Duration
+		// reset is when the first reset happens after New.
+		reset time.Duration
+	}{
+		{name: "past", start: -4 * time.Second, reset: 6 * time.Second},
+		{name: "future", start: 5 * time.Second, reset: 15 * time.Second},
+		{name: "intervals ago", start: -25 * time.Second, reset: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			l := New(WithRate(10, interval), WithClock(clock), WithStartTime(clock.Now().Add(tt.start)),
+				WithInitialUsage(10))
+			defer l.Close()
+
+			if tt.start < -interval {
+				if got := l.Stats().CaughtUp; got != 10 {
+					t.Fatalf("caught up %d, want 10", got)
+				}
+				if got := allowed(l, 10); got != 10 {
+					t.Fatalf("allowed %d after the elapsed windows, want 10", got)
+				}
+			}
+			if want := clock.Now().Add(tt.reset); !l.ResetAt().Equal(want) {
+				t.Fatalf("reset at %v, want %v", l.ResetAt(), want)
+			}
+
+			clock.Advance(tt.reset - time.Nanosecond)
+			if l.Allow() {
+				t.Fatal("allowed before the first reset")
+			}
+			clock.Advance(time.Nanosecond)
+			if got := allowed(l, 11); got != 10 {
+				t.Fatalf("allowed %d after the first reset, want 10", got)
+			}
+		})
+	}
+}
+
+func TestWaitFailFast(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(1, 40*time.Minute), WithClock(clock))
+	defer l.Close()
+
+	l.Allow()
+
+	// Infeasible: the reset is 40 minutes past the deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := l.WaitErr(ctx); !errors.Is(err, ErrWouldExceedDeadline) {
+		t.Fatalf("got %v, want ErrWouldExceedDeadline", err)
+	}
+	if l.WaitN(context.Background(), 2) {
+		t.Fatal("granted more than the limit")
+	}
+
+	// Tight but feasible: the reset comes a second before the deadline.
+	clock.Advance(40*time.Minute - time.Second)
+	done := make(chan error)
+	go func() { done <- l.WaitErr(ctx) }()
+	eventually(t, func() bool { return l.Waiters() == 1 })
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("tight wait got %v", err)
+	}
+
+	// Capacity added earlier than predicted releases the waiter at once.
+	long, cancelLong := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelLong()
+	go func() { done <- l.WaitErr(long) }()
+	eventually(t, func() bool { return l.Waiters() == 1 })
+	l.SetLimit(2)
+	if err := <-done; err != nil {
+		t.Fatalf("wait released by SetLimit got %v", err)
+	}
+}
+
+func TestCloseStopsGoroutine(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 2000; i++ {
+		l := New(WithRate(10, time.Millisecond))
+		l.Allow()
+		if err := l.Close(); err != nil {
+			t.Fatalf("Close returned %v", err)
+		}
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("%d goroutines after closing the limiters, %d before", after, before)
+	}
+
+	l := New()
+	l.Close()
+	if err := l.Close(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("second Close returned %v, want ErrClosed", err)
+	}
+}
+
+func TestCloseNoResetAfter(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(1, time.Minute), WithClock(clock), WithOnReset(func() {
+		t.Error("reset after Close")
+	}))
+	l.Allow()
+	l.Close()
+
+	clock.Advance(time.Hour)
+	time.Sleep(10 * time.Millisecond)
+}
+
+func TestLazyResetEquivalence(t *testing.T) {
+	// steps are the calls made after each advance of the clock.
+	steps := []struct {
+		advance time.Duration
+		calls   int
+	}{
+		{0, 4}, {3 * time.Second, 12}, {7 * time.Second, 3}, {25 * time.Second, 15},
+		{time.Second, 1}, {90 * time.Second, 20}, {500 * time.Millisecond, 2}, {time.Minute, 0}, {0, 11},
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "fixed", opts: []Option{WithRate(10, 10*time.Second)}},
+		{name: "gradual recovery", opts: []Option{WithRate(10, 10*time.Second), WithGradualRecovery(2, 3*time.Second)}},
+		{name: "carry over", opts: []Option{WithRate(10, 10*time.Second), WithCarryOver(5)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			eager := New(append(tt.opts, WithClock(clock))...)
+			defer eager.Close()
+			lazy := New(append(tt.opts, WithClock(clock), WithLazyReset())...)
+
+			for i, step := range steps {
+				clock.Advance(step.advance)
+				if e, l := allowed(eager, step.calls), allowed(lazy, step.calls); e != l {
+					t.Fatalf("step %d: allowed %d eager and %d lazy", i, e, l)
+				}
+				if e, l := eager.Remaining(), lazy.Remaining(); e != l {
+					t.Fatalf("step %d: remaining %d eager and %d lazy", i, e, l)
+				}
+				if e, l := eager.ResetAt(), lazy.ResetAt(); !e.Equal(l) {
+					t.Fatalf("step %d: reset at %v eager and %v lazy", i, e, l)
+				}
+				if e, l := eager.WindowID(), lazy.WindowID(); e != l {
+					t.Fatalf("step %d: window %d eager and %d lazy", i, e, l)
+				}
+			}
+		})
+	}
+}
+
+func TestNewWithContext(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l := NewWithContext(ctx, WithRate(1, time.Hour))
+	l.Allow()
+	done := make(chan bool)
+	go func() { done <- l.Wait(context.Background()) }()
+	eventually(t, func() bool { return l.Waiters() == 1 })
+
+	// The cancel closes the limiter, the waiter fails and no goroutine is left.
+	cancel()
+	if <-done {
+		t.Fatal("a waiter succeeded after the cancel")
+	}
+	if err := l.Close(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("got %v, want ErrClosed after the cancel", err)
+	}
+	eventually(t, func() bool { return runtime.NumGoroutine() <= before })
+
+	// Close before the cancel stops the goroutine watching the context too.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	l = NewWithContext(ctx, WithRate(1, time.Hour))
+	if err := l.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	eventually(t, func() bool { return runtime.NumGoroutine() <= before })
+}
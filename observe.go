@@ -0,0 +1,206 @@
+package limiter
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// observed is the state published for readers which don't take the lock.
+// It is written under the lock on every unlock and read with a sequence counter:
+// an odd or changed counter means a write was in progress.
+type observed struct {
+	seq uint64
+	// base is the monotonic reference of nextEvent, it is set by New.
+	base time.Time
+
+	limit       uint64
+	baseLimit   uint64
+	interval    int64
+	used        uint64
+	free        uint64
+	remaining   uint64
+	windowStart int64
+	// windowOffset is the window start as a monotonic offset from base.
+	windowOffset int64
+	nextReset    int64
+	windowID     uint64
+	// nextEvent is when the published state gets stale as a monotonic offset from base,
+	// math.MaxInt64 if nothing is scheduled.
+	nextEvent int64
+	gradual   uint32
+	waiters   int64
+
+	allowed         uint64
+	denied          uint64
+	shed            uint64
+	filtered        uint64
+	blocked         uint64
+	granted         uint64
+	wouldHaveDenied uint64
+	carried         uint64
+	caughtUp        uint64
+	waited          uint64
+	waitDurations   [len(WaitBuckets) + 1]uint64
+}
+
+// publishLocked publishes the state for the readers.
+func (l *Limiter) publishLocked() {
+	now := l.lastNow
+	if now.IsZero() {
+		now = l.windowStart
+	}
+	state := l.stateLocked(now)
+
+	nextEvent := int64(math.MaxInt64)
+	if next := l.nextEventLocked(); !next.IsZero() {
+		nextEvent = int64(next.Sub(l.observed.base))
+	}
+
+	var gradual uint32
+	if state.GradualRecovery {
+		gradual = 1
+	}
+
+	o := &l.observed
+	atomic.AddUint64(&o.seq, 1)
+	atomic.StoreUint64(&o.limit, uint64(state.Limit))
+	atomic.StoreUint64(&o.baseLimit, uint64(l.limit))
+	atomic.StoreInt64(&o.interval, int64(l.interval))
+	atomic.StoreUint64(&o.used, uint64(state.Used))
+	atomic.StoreUint64(&o.free, uint64(l.freeLocked()))
+	atomic.StoreUint64(&o.remaining, uint64(state.Remaining))
+	atomic.StoreInt64(&o.windowStart, state.WindowStart.UnixNano())
+	atomic.StoreInt64(&o.windowOffset, int64(l.windowStart.Sub(o.base)))
+	atomic.StoreInt64(&o.nextReset, state.NextReset.UnixNano())
+	atomic.StoreUint64(&o.windowID, state.WindowID)
+	atomic.StoreInt64(&o.nextEvent, nextEvent)
+	atomic.StoreUint32(&o.gradual, gradual)
+	atomic.StoreInt64(&o.waiters, int64(state.Waiters))
+	atomic.StoreUint64(&o.allowed, l.stats.Allowed)
+	atomic.StoreUint64(&o.denied, l.stats.Denied)
+	atomic.StoreUint64(&o.shed, l.stats.Shed)
+	atomic.StoreUint64(&o.filtered, l.stats.Filtered)
+	atomic.StoreUint64(&o.blocked, l.stats.Blocked)
+	atomic.StoreUint64(&o.granted, l.stats.Granted)
+	atomic.StoreUint64(&o.wouldHaveDenied, l.stats.WouldHaveDenied)
+	atomic.StoreUint64(&o.carried, uint64(l.carried))
+	atomic.StoreUint64(&o.caughtUp, uint64(l.stats.CaughtUp))
+	atomic.StoreUint64(&o.waited, l.stats.Waited)
+	for i := range o.waitDurations {
+		atomic.StoreUint64(&o.waitDurations[i], l.stats.WaitDurations[i])
+	}
+	atomic.AddUint64(&o.seq, 1)
+}
+
+// loadObserved reads the published state without the lock.
+// It returns false if a reset or a recovery step is due since it was published,
+// then the caller has to take the lock to apply it.
+func (l *Limiter) loadObserved(now time.Time) (State, Stats, bool) {
+	o := &l.observed
+	for {
+		seq := atomic.LoadUint64(&o.seq)
+		if seq%2 == 1 {
+			continue
+		}
+
+		state := State{
+			Limit:           Limit(atomic.LoadUint64(&o.limit)),
+			Used:            Limit(atomic.LoadUint64(&o.used)),
+			Remaining:       Limit(atomic.LoadUint64(&o.remaining)),
+			WindowStart:     time.Unix(0, atomic.LoadInt64(&o.windowStart)),
+			NextReset:       time.Unix(0, atomic.LoadInt64(&o.nextReset)),
+			WindowID:        atomic.LoadUint64(&o.windowID),
+			GradualRecovery: atomic.LoadUint32(&o.gradual) == 1,
+			Waiters:         int(atomic.LoadInt64(&o.waiters)),
+		}
+		stats := Stats{
+			Allowed:         atomic.LoadUint64(&o.allowed),
+			Denied:          atomic.LoadUint64(&o.denied),
+			Shed:            atomic.LoadUint64(&o.shed),
+			Filtered:        atomic.LoadUint64(&o.filtered),
+			Blocked:         atomic.LoadUint64(&o.blocked),
+			Granted:         atomic.LoadUint64(&o.granted),
+			WouldHaveDenied: atomic.LoadUint64(&o.wouldHaveDenied),
+			Carried:         Limit(atomic.LoadUint64(&o.carried)),
+			CaughtUp:        Limit(atomic.LoadUint64(&o.caughtUp)),
+			Waited:          atomic.LoadUint64(&o.waited),
+			Waiters:         state.Waiters,
+		}
+		for i := range stats.WaitDurations {
+			stats.WaitDurations[i] = atomic.LoadUint64(&o.waitDurations[i])
+		}
+		nextEvent := atomic.LoadInt64(&o.nextEvent)
+
+		if atomic.LoadUint64(&o.seq) != seq {
+			continue
+		}
+
+		return state, stats, int64(now.Sub(o.base)) < nextEvent
+	}
+}
+
+// loadFresh reads a value published with the state without the lock.
+// It returns false if a reset or a recovery step is due since it was published.
+func (l *Limiter) loadFresh(now time.Time, load func(o *observed) uint64) (uint64, bool) {
+	o := &l.observed
+	for {
+		seq := atomic.LoadUint64(&o.seq)
+		if seq%2 == 1 {
+			continue
+		}
+
+		v := load(o)
+		nextEvent := atomic.LoadInt64(&o.nextEvent)
+
+		if atomic.LoadUint64(&o.seq) != seq {
+			continue
+		}
+
+		return v, int64(now.Sub(o.base)) < nextEvent
+	}
+}
+
+// freeLocked returns the max number of units available for a call without the reserved units,
+// ignoring the overdraft.
+func (l *Limiter) freeLocked() Limit {
+	budget := l.budgetLocked()
+	if budget < l.reserved || !l.spacedLocked() {
+		return 0
+	}
+
+	free, used := budget-l.reserved, l.usageLocked()
+	if used >= free {
+		return 0
+	}
+	free -= used
+	for i := range l.quotas {
+		if left := l.quotas[i].remaining(); left < free {
+			free = left
+		}
+	}
+
+	return free
+}
+
+// loadWindow reads the window start as a monotonic offset from base and the interval
+// without the lock, see loadFresh.
+func (l *Limiter) loadWindow(now time.Time) (offset, interval time.Duration, fresh bool) {
+	o := &l.observed
+	for {
+		seq := atomic.LoadUint64(&o.seq)
+		if seq%2 == 1 {
+			continue
+		}
+
+		offset = time.Duration(atomic.LoadInt64(&o.windowOffset))
+		interval = time.Duration(atomic.LoadInt64(&o.interval))
+		nextEvent := atomic.LoadInt64(&o.nextEvent)
+
+		if atomic.LoadUint64(&o.seq) != seq {
+			continue
+		}
+
+		return offset, interval, int64(now.Sub(o.base)) < nextEvent
+	}
+}
@@ -0,0 +1,140 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestObservedReads(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "fixed"},
+		{name: "reserved", opts: []Option{WithReserved(1)}},
+		{name: "quota", opts: []Option{WithQuota(3, 20*time.Second)}},
+		{name: "gradual", opts: []Option{WithGradualRecovery(1, 10*time.Second)}},
+		{name: "sliding window", opts: []Option{WithSlidingWindow()}},
+		{name: "sliding log", opts: []Option{WithSlidingLog(6)}},
+		{name: "smoothing", opts: []Option{WithSmoothing()}},
+		{name: "lazy", opts: []Option{WithLazyReset()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			l := New(append([]Option{WithRate(5, time.Minute), WithClock(clock)}, tt.opts...)...)
+			defer l.Close()
+
+			// check compares the reads without the lock with the state under the lock at the same time.
+			check := func(step int) {
+				t.Helper()
+
+				limit, interval, waiters := l.Limit(), l.Interval(), l.Waiters()
+				elapsed, total := l.WindowProgress()
+				var peeks [7]bool
+				for n := range peeks {
+					peeks[n] = l.PeekN(Limit(n))
+				}
+
+				now := l.lock()
+				defer l.unlock()
+
+				if limit != l.limit || interval != l.interval || waiters != l.waiters.len() {
+					t.Fatalf("step %d: limit %d, interval %v, waiters %d, want %d, %v and %d",
+						step, limit, interval, waiters, l.limit, l.interval, l.waiters.len())
+				}
+				want := now.Sub(l.windowStart)
+				if want < 0 {
+					want = 0
+				}
+				want %= l.interval
+				if elapsed != want || total != l.interval {
+					t.Fatalf("step %d: progress %v of %v, want %v of %v", step, elapsed, total, want, l.interval)
+				}
+				for n, ok := range peeks {
+					if want := l.availableLocked(Limit(n), l.reserved); ok != want {
+						t.Fatalf("step %d: PeekN(%d) is %t, want %t", step, n, ok, want)
+					}
+				}
+			}
+
+			for step := 0; step < 30; step++ {
+				allowed(l, step%4)
+				if step == 7 {
+					l.SetLimit(6)
+				}
+				check(step)
+
+				if step%5 == 0 && !l.Peek() {
+					ctx, cancel := context.WithCancel(context.Background())
+					done := make(chan error, 2)
+					for i := 0; i < 2; i++ {
+						go func() { done <- l.WaitErr(ctx) }()
+					}
+					eventually(t, func() bool { return l.Waiters() == 2 })
+					check(step)
+					cancel()
+					<-done
+					<-done
+				}
+
+				// The published state is stale until it is read.
+				clock.Advance(7 * time.Second)
+				check(step)
+			}
+		})
+	}
+}
+
+// BenchmarkCurrentContended reads the usage while 64 goroutines spin on Allow.
+// The locked variant reads it under the lock the way Current did before the state was published.
+func BenchmarkCurrentContended(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		read func(l *Limiter) Limit
+	}{
+		{name: "observed", read: (*Limiter).Current},
+		{name: "locked", read: func(l *Limiter) Limit {
+			l.lock()
+			defer l.unlock()
+
+			return l.current
+		}},
+	}
+
+	for _, bench := range benchmarks {
+		b.Run(bench.name, func(b *testing.B) {
+			l := New(WithRate(1<<20, time.Minute))
+			defer l.Close()
+
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < 64; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+							l.Allow()
+						}
+					}
+				}()
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bench.read(l)
+			}
+			b.StopTimer()
+
+			close(stop)
+			wg.Wait()
+		})
+	}
+}
@@ -2,23 +2,451 @@ package limiter
 
 import "time"
 
+// Option configures a Limiter, see New.
+type Option func(*Limiter)
+
+// WithName set Limiter.name.
+// The name is used to tell limiters apart, for example by Registry.
+func WithName(name string) Option {
+	return func(l *Limiter) {
+		l.name = name
+	}
+}
+
 // WithMaxLimit set Limiter.limit.
-func WithMaxLimit(limit Limit) func(*Limiter) {
+// The limit is counted per interval, see WithInterval.
+func WithMaxLimit(limit Limit) Option {
 	return func(l *Limiter) {
 		l.limit = limit
 	}
 }
 
 // WithInterval set Limiter.interval.
-func WithInterval(interval time.Duration) func(*Limiter) {
+func WithInterval(interval time.Duration) Option {
 	return func(l *Limiter) {
 		l.interval = interval
 	}
 }
 
-// WithGradualRecovery set Limiter.gradualRecovery and Limiter.recoveryRate.
-func WithGradualRecovery() func(*Limiter) {
+// WithRate set Limiter.limit and Limiter.interval together,
+// so the limiter allows events per the duration per.
+// It is the same as WithMaxLimit(Limit(events)) with WithInterval(per),
+// but validates both values: it panics if events or per is not positive.
+func WithRate(events uint64, per time.Duration) Option {
+	if events == 0 {
+		panic("limiter: WithRate events must be positive")
+	}
+	if per <= 0 {
+		panic("limiter: WithRate per must be positive")
+	}
+
+	return func(l *Limiter) {
+		l.limit = Limit(events)
+		l.interval = per
+	}
+}
+
+// WithReserved set Limiter.reserved.
+// The top n units of the limit are granted only by AllowReserved,
+// Allow and Wait treat the limit as limit minus n.
+// Reserved usage counts toward the window and resets with it.
+// Gradual recovery restores the reserved units first, because they are the top of the limit.
+func WithReserved(n Limit) Option {
+	return func(l *Limiter) {
+		l.reserved = n
+	}
+}
+
+// WithSlidingLog switch the limiter to the sliding log algorithm.
+// It keeps a timestamp per admitted event and admits an event only when
+// fewer than limit events happened in the trailing interval, so there are no window boundaries.
+// Memory is bounded by the limit, New panics if the limit is Infinite or greater than maxEntries.
+// Gradual recovery is not used in this mode.
+func WithSlidingLog(maxEntries int) Option {
+	if maxEntries <= 0 {
+		panic("limiter: WithSlidingLog maxEntries must be positive")
+	}
+
+	return func(l *Limiter) {
+		l.logEntries = maxEntries
+	}
+}
+
+// WithShadowMode set Limiter.shadow.
+// In shadow mode the limiter does all the accounting and reports denials,
+// but Allow and Wait let every call through. Calls over the limit don't consume units,
+// so the counters are the same as in enforcing mode.
+func WithShadowMode() Option {
+	return func(l *Limiter) {
+		l.shadow = true
+	}
+}
+
+// WithOnDeny set a callback which is called for every call exceeded the limit,
+// including the calls let through by shadow mode. It runs outside the limiter lock.
+func WithOnDeny(fn func(DenyInfo)) Option {
+	return func(l *Limiter) {
+		l.onDeny = fn
+	}
+}
+
+// WithOnEvent set a callback which is called for every consumption, denial
+// and blocked wait, see Event. It runs outside the limiter lock.
+func WithOnEvent(fn func(Event)) Option {
+	return func(l *Limiter) {
+		l.onEvent = fn
+	}
+}
+
+// WithDenyPolicy set Limiter.policy, the default is Reject.
+// The policy is used by Allow only: AllowReserved always rejects and
+// Wait always queues. Shadow mode overrides the policy, nothing is delayed or shed.
+func WithDenyPolicy(p Policy) Option {
+	return func(l *Limiter) {
+		l.policy = p
+	}
+}
+
+// WithEarlyShed set Limiter.policy to Shed(start).
+// Allow rejects a growing part of calls once the usage passes start fraction of the limit,
+// Wait isn't shed and queues as usual. It panics if start is not in [0, 1).
+func WithEarlyShed(start float64) Option {
+	return WithDenyPolicy(Shed(start))
+}
+
+// WithRandom set Limiter.random, the source of random numbers in [0, 1) used by the Shed policy.
+// It is called under the limiter lock. The default is rand.Float64.
+func WithRandom(fn func() float64) Option {
+	return func(l *Limiter) {
+		l.random = fn
+	}
+}
+
+// WithRateHorizon set the period Limiter.Rate is estimated over, the default is one minute.
+func WithRateHorizon(horizon time.Duration) Option {
+	return func(l *Limiter) {
+		l.rateHorizon = horizon
+	}
+}
+
+// WithThreshold add a callback which is called once per window
+// when the usage first reaches fraction of the limit.
+// It can be passed several times, the callbacks run outside the limiter lock.
+// It panics if fraction is not in (0, 1].
+func WithThreshold(fraction float64, fn func(used, limit Limit)) Option {
+	if !(fraction > 0 && fraction <= 1) {
+		panic("limiter: WithThreshold fraction must be in (0, 1]")
+	}
+
+	return func(l *Limiter) {
+		l.thresholds = append(l.thresholds, threshold{fraction: fraction, fn: fn})
+	}
+}
+
+// WithOnExhausted add a callback which is called once per window
+// when the whole limit is consumed, it is WithThreshold with the full limit.
+func WithOnExhausted(fn func()) Option {
+	return WithThreshold(1, func(Limit, Limit) { fn() })
+}
+
+// WithOnReset set a callback which is called when the window ends,
+// once per access even if several windows passed. It runs outside the limiter lock.
+func WithOnReset(fn func()) Option {
+	return func(l *Limiter) {
+		l.onReset = fn
+	}
+}
+
+// WithThresholdRearm set Limiter.rearmThresholds.
+// With gradual recovery, a threshold is armed again when the usage drops below it,
+// so it can fire several times within one window.
+func WithThresholdRearm() Option {
+	return func(l *Limiter) {
+		l.rearmThresholds = true
+	}
+}
+
+// WithBurnRateAlert set Limiter.burn.
+// The callback is called once per window when the used part of the limit divided by
+// the elapsed part of the interval reaches threshold, e.g. 1.25 fires when the limit
+// would run out with 20% of the window left. The ratio is checked on consumption,
+// so a burst right after the window start fires it too. The callback runs outside the limiter lock.
+// It isn't used with gradual recovery or sliding log, they have no fixed window.
+func WithBurnRateAlert(threshold float64, fn func(BurnInfo)) Option {
+	return func(l *Limiter) {
+		l.burn = &burnAlert{threshold: threshold, fn: fn}
+	}
+}
+
+// WithHistory set Limiter.history.
+// The usage of the last n finished windows is kept, see Limiter.History.
+// With gradual recovery or sliding log a record is taken at every interval boundary.
+// It panics if n is not positive.
+func WithHistory(n int) Option {
+	if n <= 0 {
+		panic("limiter: WithHistory n must be positive")
+	}
+
+	return func(l *Limiter) {
+		l.history = &history{records: make([]WindowRecord, n)}
+	}
+}
+
+// WithFilter add a check which must pass before any unit is consumed.
+// It can be passed several times. If a filter returns false, the call is denied without
+// consuming anything and counted in Stats.Filtered. Filters run outside the limiter lock.
+// Wait checks them before queuing and again when a blocked waiter is granted,
+// then the units are returned and Wait fails, see WithFilterRequeue.
+func WithFilter(fn func() bool) Option {
+	return func(l *Limiter) {
+		l.filters = append(l.filters, fn)
+	}
+}
+
+// WithFilterRequeue set Limiter.filterRequeue.
+// A blocked waiter whose filters fail when it is granted returns the units
+// and queues again behind the other waiters instead of failing.
+// It is granted again no earlier than the next reset, recovery step or returned unit.
+func WithFilterRequeue() Option {
+	return func(l *Limiter) {
+		l.filterRequeue = true
+	}
+}
+
+// WithMaxWaiters set Limiter.maxWaiters, by default the number of waiters is unlimited.
+// When n goroutines are already blocked in Wait, further Wait calls return false without queuing.
+// With n equal to zero Wait never blocks and works like Allow.
+func WithMaxWaiters(n int) Option {
+	if n < 0 {
+		panic("limiter: WithMaxWaiters n must not be negative")
+	}
+
+	return func(l *Limiter) {
+		l.maxWaiters = n
+	}
+}
+
+// WithSubLimit add a short window counted inside the main one:
+// Allow denies when either limit is exceeded and Wait blocks until both allow.
+// For example, WithRate(1000, time.Hour) with WithSubLimit(10, time.Second)
+// spaces the hourly quota by at most 10 events per second.
+// It panics if per is not positive.
+func WithSubLimit(limit Limit, per time.Duration) Option {
+	if per <= 0 {
+		panic("limiter: WithSubLimit per must be positive")
+	}
+
+	return WithQuota(limit, per)
+}
+
+// WithQuota add a fixed window of limit events per interval enforced together with the main limit,
+// it can be repeated for compound limits like 10 per second and 1000 per hour.
+// Allow checks all windows at once and consumes from all of them, Remaining reports the tightest.
+// It panics if interval is not positive.
+func WithQuota(limit Limit, interval time.Duration) Option {
+	if interval <= 0 {
+		panic("limiter: WithQuota interval must be positive")
+	}
+
+	return func(l *Limiter) {
+		l.quotas = append(l.quotas, quota{limit: limit, interval: interval})
+	}
+}
+
+// WithOverageMode set Limiter.overage, the default is OverageDeny.
+// It is used when SetLimit lowers the limit below the current usage and when the window resets.
+func WithOverageMode(mode OverageMode) Option {
+	return func(l *Limiter) {
+		l.overage = mode
+	}
+}
+
+// WithCarryOver set Limiter.maxCarried.
+// At every window reset the unused part of the budget is added to the next window,
+// the carried amount is capped at maxAccumulated. For example, WithCarryOver(limit)
+// lets a window grow up to twice the limit. It isn't used with gradual recovery or sliding log.
+func WithCarryOver(maxAccumulated Limit) Option {
+	return func(l *Limiter) {
+		l.maxCarried = maxAccumulated
+	}
+}
+
+// WithOverdraft set Limiter.maxDebt.
+// A cost larger than the rest of the budget is allowed while some budget is left,
+// the usage may go above the budget up to maxDebt and the debt is paid by the next windows
+// the same way as in OverageDebt mode. It isn't used in sliding log mode.
+func WithOverdraft(maxDebt Limit) Option {
+	return func(l *Limiter) {
+		l.maxDebt = maxDebt
+	}
+}
+
+// WithStrict set Limiter.strict.
+// Misuse panics instead of being silently tolerated: Allow, AllowReserved or SetLimit after Close,
+// Wait after Close or with a done context. AllowErr returns ErrClosed after Close.
+// It is meant for tests and staging, Close of a lazy limiter isn't tracked.
+func WithStrict() Option {
+	return func(l *Limiter) {
+		l.strict = true
+	}
+}
+
+// WithExternalRefill set Limiter.external.
+// The limiter starts empty and never resets, units are added by Limiter.AddCapacity only,
+// e.g. when a coordinator hands out quota to instances. The limit is the max capacity
+// which can be outstanding. Gradual recovery and carry-over aren't used and
+// RetryAfter is Never while the limiter is empty. Used is the limit minus the available capacity.
+// It panics with sliding log.
+func WithExternalRefill() Option {
+	return func(l *Limiter) {
+		l.external = true
+	}
+}
+
+// WithLazyReset set Limiter.lazy.
+// The limiter doesn't start the cleanup goroutine, resets and recovery steps
+// are applied on access from the window start, and blocked waiters apply them
+// when they are due. Close does nothing in this mode.
+func WithLazyReset() Option {
+	return func(l *Limiter) {
+		l.lazy = true
+	}
+}
+
+// WithSharedSchedule set Limiter.group.
+// The limiter joins the group and takes its interval and window phase,
+// window resets of all members happen at the same instant. Close leaves the group.
+// It can't be used with WithSlidingLog, the group must use the clock of the limiter.
+func WithSharedSchedule(g *ResetGroup) Option {
+	return func(l *Limiter) {
+		l.group = g
+	}
+}
+
+// WithStartTime set Limiter.windowStart.
+// Use it to continue a window which began before the limiter was created:
+// the first reset happens at t plus interval, or immediately if that time has passed.
+func WithStartTime(t time.Time) Option {
+	return func(l *Limiter) {
+		l.windowStart = t
+	}
+}
+
+// WithInitialUsage set Limiter.current.
+// Together with WithStartTime it restores the usage of a window started earlier.
+// New applies the resets and recovery steps due since the start time at once,
+// the restored amount is reported in Stats.CaughtUp. A lazy limiter applies them on first access.
+func WithInitialUsage(used Limit) Option {
+	return func(l *Limiter) {
+		l.current = used
+	}
+}
+
+// WithState set Limiter.windowStart, Limiter.current and Limiter.windowID from a State
+// saved before a restart, so the usage of the window isn't lost. It is WithStartTime
+// and WithInitialUsage together, State can be stored as JSON for it.
+// Carried units are not restored. With gradual recovery the steps since the window start
+// are applied again, so the restored usage can be lower than it was.
+func WithState(s State) Option {
+	return func(l *Limiter) {
+		l.windowStart = s.WindowStart
+		l.current = s.Used
+		l.windowID = s.WindowID
+	}
+}
+
+// WithTokenBucket set Limiter.refill, Limiter.limit and Limiter.gradualRecovery.
+// The limiter works as a token bucket of burst units refilled continuously
+// by rate units per interval, see WithInterval, instead of resetting at the window end.
+// It panics if rate or burst is zero.
+func WithTokenBucket(rate Limit, burst uint64) Option {
+	if rate == 0 || burst == 0 {
+		panic("limiter: WithTokenBucket rate and burst must be positive")
+	}
+
+	return func(l *Limiter) {
+		l.refill = rate
+		l.limit = Limit(burst)
+		l.gradualRecovery = true
+	}
+}
+
+// WithClock set Limiter.clock.
+// All the time of the limiter comes from the clock, including the timers
+// of the cleanup goroutine and of waiters, so tests can advance a fake clock
+// instead of sleeping. A shared schedule must use the same clock, see WithGroupClock.
+// If the clock steps back, the limiter keeps counting from its latest reading,
+// so the times it reports are ahead of the clock by the step.
+func WithClock(c Clock) Option {
+	return func(l *Limiter) {
+		l.clock = c
+	}
+}
+
+// WithAdaptive set Limiter.adaptive.
+// After every window the probe is called outside the lock and the limit is adjusted
+// within minLimit and maxLimit: it is halved on SignalOverloaded and grows by a tenth
+// of the range on SignalHealthy, so sustained failures shrink it quickly
+// and it recovers gradually. The limit starts at maxLimit unless WithMaxLimit sets it within the range.
+// It panics if the probe is nil, minLimit is greater than maxLimit or maxLimit is Infinite.
+func WithAdaptive(probe func() Signal, minLimit, maxLimit Limit) Option {
+	if probe == nil {
+		panic("limiter: WithAdaptive probe must not be nil")
+	}
+	if minLimit > maxLimit || maxLimit == Infinite {
+		panic("limiter: WithAdaptive range must be finite and not empty")
+	}
+
+	return func(l *Limiter) {
+		l.adaptive = &adaptive{probe: probe, min: minLimit, max: maxLimit}
+	}
+}
+
+// WithSmoothing set Limiter.smoothing.
+// Grants are spaced evenly by interval divided by the limit, like a leaky bucket:
+// Allow denies until the gap after the previous grant has passed and Wait returns
+// at a steady cadence. The limit per interval is enforced as well.
+func WithSmoothing() Option {
+	return func(l *Limiter) {
+		l.smoothing = true
+	}
+}
+
+// WithWarmup set Limiter.warmup.
+// After New the limit ramps linearly from a tenth of it up to the full limit over d,
+// so a restarted service isn't hit with the whole limit at once.
+// Carried units are added to the lowered limit as usual.
+// Allow and Wait see the exact limit, the reported state grows in a hundred steps.
+func WithWarmup(d time.Duration) Option {
+	return func(l *Limiter) {
+		l.warmup = d
+	}
+}
+
+// WithSlidingWindow set Limiter.slidingWindow.
+// The usage of the previous window is counted too, weighted by the part of it
+// which is still in the trailing interval, so a client can't use the limit twice
+// around a window boundary. The extra memory is a single counter.
+// It is not used with gradual recovery, the sliding log or external refill.
+func WithSlidingWindow() Option {
+	return func(l *Limiter) {
+		l.slidingWindow = true
+	}
+}
+
+// WithGradualRecovery set Limiter.gradualRecovery, Limiter.recoveryStep and Limiter.recoveryEvery.
+// Instead of resetting at the window end, step units are restored every period.
+// A zero step spreads the limit over the interval, so the whole limit is restored
+// exactly by the end of each interval. It panics if step is set and every is not positive.
+// The option used to take no arguments, WithGradualRecovery(0, 0) behaves as it did, see CHANGELOG.md.
+func WithGradualRecovery(step Limit, every time.Duration) Option {
+	if step != 0 && every <= 0 {
+		panic("limiter: WithGradualRecovery every must be positive")
+	}
+
 	return func(l *Limiter) {
 		l.gradualRecovery = true
+		l.recoveryStep = step
+		l.recoveryEvery = every
 	}
 }
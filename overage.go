@@ -0,0 +1,151 @@
+package limiter
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// OverageMode defines what happens with the usage above the limit
+// after the limit was lowered by SetLimit, see WithOverageMode.
+type OverageMode uint8
+
+const (
+	// OverageDeny keeps the usage, so calls are denied until the window resets.
+	OverageDeny OverageMode = iota
+	// OverageForgive clamps the usage to the new limit immediately.
+	OverageForgive
+	// OverageDebt keeps the usage and carries the part above the limit into the next window,
+	// reducing its budget. With gradual recovery it behaves like OverageDeny,
+	// because the usage is restored step by step anyway.
+	OverageDebt
+)
+
+// SetLimit changes the limit of a running limiter.
+// Raising the limit wakes blocked waiters. If the usage is above a lowered limit,
+// the overage is handled according to the overage mode.
+// In sliding log mode it panics if limit is greater than max entries.
+func (l *Limiter) SetLimit(limit Limit) {
+	l.checkNew()
+	l.mu.Lock()
+	l.checkStrictLocked("SetLimit")
+	now := l.now()
+	l.advanceLocked(now)
+
+	if l.log != nil && (limit == Infinite || limit > Limit(l.logEntries)) {
+		l.mu.Unlock()
+		panic("limiter: sliding log requires a limit not greater than max entries")
+	}
+
+	l.setLimitLocked(limit, now)
+	l.unlock()
+
+	l.wakeCleanup()
+}
+
+// setLimitLocked is SetLimit under the lock, the limit is already validated.
+func (l *Limiter) setLimitLocked(limit Limit, now time.Time) {
+	if l.log != nil {
+		l.log.resize(int(limit))
+	}
+
+	if l.external {
+		// Keep the added capacity, up to the new limit.
+		available := l.windowRemainingLocked()
+		if available > limit {
+			available = limit
+		}
+		l.current = limit - available
+	}
+
+	l.limit = limit
+	if l.overage == OverageForgive && l.current > limit {
+		l.current = limit
+	}
+
+	l.grantWaitersLocked(now)
+}
+
+// SetInterval changes the interval of a running limiter.
+// The current window keeps its start and ends after the new interval, it is reset at once
+// if that time has passed. Gradual recovery continues with the new rate from now.
+// It panics if interval is not positive or the limiter uses a shared schedule or aligned windows.
+func (l *Limiter) SetInterval(interval time.Duration) {
+	l.checkNew()
+	if interval <= 0 {
+		panic("limiter: interval must be positive")
+	}
+
+	l.mu.Lock()
+	l.checkStrictLocked("SetInterval")
+	if l.group != nil || l.calendar != nil {
+		l.mu.Unlock()
+		panic("limiter: SetInterval on a shared schedule or aligned windows")
+	}
+
+	now := l.now()
+	l.advanceLocked(now)
+	l.interval = interval
+	l.restartRecoveryLocked(now)
+	l.advanceLocked(now)
+	l.unlock()
+
+	l.wakeCleanup()
+}
+
+// Limit returns the limit without carried units, see SetLimit.
+// It doesn't take the lock.
+func (l *Limiter) Limit() Limit {
+	l.checkNew()
+	return Limit(atomic.LoadUint64(&l.observed.baseLimit))
+}
+
+// Interval returns the interval, see SetInterval.
+// It doesn't take the lock.
+func (l *Limiter) Interval() time.Duration {
+	l.checkNew()
+	return time.Duration(atomic.LoadInt64(&l.observed.interval))
+}
+
+// resetLocked starts a new window after the number of windows passed.
+func (l *Limiter) resetLocked(windows Limit) {
+	if l.slidingWindow {
+		l.previous = 0
+		if windows == 1 {
+			l.previous = l.current
+		}
+	}
+	l.current, l.carried = l.afterResetLocked(windows)
+}
+
+// afterResetLocked returns the usage and the carried units after the number of window resets.
+// The usage above the budget is carried as debt in OverageDebt mode,
+// otherwise the unused budget is carried over if WithCarryOver is set.
+func (l *Limiter) afterResetLocked(windows Limit) (current, carried Limit) {
+	budget := l.budgetLocked()
+
+	if (l.overage == OverageDebt || l.overdraftLocked()) && l.current > budget {
+		// The following windows pay the rest of the debt with their limit.
+		debt, windows := l.current-budget, windows-1
+		if l.limit != 0 && debt/l.limit < windows {
+			return 0, 0
+		}
+
+		return debt - windows*l.limit, 0
+	}
+
+	if l.maxCarried == 0 {
+		return 0, 0
+	}
+
+	// The windows after the current one were idle, their whole limit is unused.
+	var unused Limit
+	if l.current < budget {
+		unused = budget - l.current
+	}
+	carried = addLimit(unused, mulLimit(windows-1, l.limit))
+	if carried > l.maxCarried {
+		carried = l.maxCarried
+	}
+
+	return 0, carried
+}
@@ -0,0 +1,94 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetLimitOverage(t *testing.T) {
+	tests := []struct {
+		mode OverageMode
+		// used is the usage after the limit is lowered.
+		used Limit
+		// next is the number of units allowed in the next window.
+		next int
+	}{
+		{mode: OverageDeny, used: 8, next: 5},
+		{mode: OverageForgive, used: 5, next: 5},
+		{mode: OverageDebt, used: 8, next: 2},
+	}
+
+	for _, tt := range tests {
+		clock := newFakeClock()
+		l := New(WithRate(10, time.Minute), WithClock(clock), WithOverageMode(tt.mode))
+
+		allowed(l, 8)
+		l.SetLimit(5)
+		if s := l.State(); s.Used != tt.used || s.Remaining != 0 || s.Limit != 5 {
+			t.Errorf("mode %d: state %+v after lowering the limit", tt.mode, s)
+		}
+		if l.Allow() {
+			t.Errorf("mode %d: allowed above the lowered limit", tt.mode)
+		}
+
+		clock.Advance(time.Minute)
+		if got := allowed(l, 10); got != tt.next {
+			t.Errorf("mode %d: allowed %d in the next window, want %d", tt.mode, got, tt.next)
+		}
+		l.Close()
+	}
+}
+
+func TestSetLimitOverageGradualRecovery(t *testing.T) {
+	for _, mode := range []OverageMode{OverageDeny, OverageForgive, OverageDebt} {
+		clock := newFakeClock()
+		l := New(WithRate(10, 10*time.Second), WithClock(clock), WithOverageMode(mode), WithGradualRecovery(0, 0))
+
+		allowed(l, 8)
+		l.SetLimit(5)
+
+		// Lowered to 5 per 10 seconds, a unit is restored every 2 seconds.
+		for i := 0; i < 20; i++ {
+			s := l.State()
+			if s.Remaining > s.Limit || (s.Used >= s.Limit && s.Remaining != 0) || s.Used+s.Remaining < s.Limit {
+				t.Fatalf("mode %d: inconsistent state %+v", mode, s)
+			}
+			clock.Advance(time.Second)
+		}
+		if got := allowed(l, 10); got != 5 {
+			t.Errorf("mode %d: allowed %d once recovered, want 5", mode, got)
+		}
+		l.Close()
+	}
+}
+
+func TestOverdraft(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(10, time.Minute), WithClock(clock), WithOverdraft(25))
+	defer l.Close()
+
+	allowed(l, 5)
+	if l.AllowN(31) {
+		t.Fatal("allowed a cost above the rest of the budget and the overdraft")
+	}
+	if !l.AllowN(30) {
+		t.Fatal("denied a cost within the rest of the budget and the overdraft")
+	}
+	if l.Allow() {
+		t.Fatal("allowed in debt")
+	}
+	if got := l.RetryAfter(); got != 3*time.Minute {
+		t.Fatalf("retry after %v, want the debt paid in 3 minutes", got)
+	}
+
+	// The next windows pay the debt of 25 with their limit.
+	for _, want := range []struct{ used, remaining Limit }{{25, 0}, {15, 0}, {5, 5}, {0, 10}} {
+		clock.Advance(time.Minute)
+		if used, remaining := l.Used(), l.Remaining(); used != want.used || remaining != want.remaining {
+			t.Fatalf("used %d and remaining %d, want %d and %d", used, remaining, want.used, want.remaining)
+		}
+	}
+	if got := allowed(l, 11); got != 10 {
+		t.Fatalf("allowed %d after the debt was paid, want 10", got)
+	}
+}
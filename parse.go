@@ -0,0 +1,134 @@
+package limiter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var limitUnits = []struct {
+	suffix string
+	per    time.Duration
+}{
+	{"s", time.Second},
+	{"m", time.Minute},
+	{"h", time.Hour},
+	{"d", 24 * time.Hour},
+}
+
+// ParseLimit parses a limit per interval like "500/m", "10k/h" or "inf".
+// The count may have a k (thousand) or m (million) suffix, the interval is one of
+// s, m, h, d or a duration like "30s". "inf" and "infinite" are Infinite with no interval.
+func ParseLimit(s string) (Limit, time.Duration, error) {
+	if isInfinite(s) {
+		return Infinite, 0, nil
+	}
+
+	count, unit, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("limiter: invalid limit %q: want count/interval", s)
+	}
+
+	limit, err := parseCount(count)
+	if err == nil && limit == 0 {
+		err = fmt.Errorf("count must be positive")
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("limiter: invalid limit %q: %w", s, err)
+	}
+
+	for _, u := range limitUnits {
+		if unit == u.suffix {
+			return limit, u.per, nil
+		}
+	}
+
+	per, err := time.ParseDuration(unit)
+	if err != nil || per <= 0 {
+		return 0, 0, fmt.Errorf("limiter: invalid limit %q: interval must be s, m, h, d or a positive duration", s)
+	}
+
+	return limit, per, nil
+}
+
+// FormatLimit is the reverse of ParseLimit.
+func FormatLimit(limit Limit, per time.Duration) string {
+	if limit == Infinite {
+		return "inf"
+	}
+
+	unit := per.String()
+	for _, u := range limitUnits {
+		if per == u.per {
+			unit = u.suffix
+		}
+	}
+
+	return formatCount(limit) + "/" + unit
+}
+
+// MarshalText implements encoding.TextMarshaler, Infinite is "inf".
+func (l Limit) MarshalText() ([]byte, error) {
+	if l == Infinite {
+		return []byte("inf"), nil
+	}
+
+	return []byte(strconv.FormatUint(uint64(l), 10)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// It accepts a count with an optional k or m suffix, "inf" or "infinite".
+// Unlike ParseLimit, zero is valid: Limit is also used for usage counts.
+func (l *Limit) UnmarshalText(text []byte) error {
+	if isInfinite(string(text)) {
+		*l = Infinite
+		return nil
+	}
+
+	limit, err := parseCount(string(text))
+	if err != nil {
+		return fmt.Errorf("limiter: invalid limit %q: %w", text, err)
+	}
+	*l = limit
+
+	return nil
+}
+
+func isInfinite(s string) bool {
+	return s == "inf" || s == "infinite"
+}
+
+// parseCount parses a count with an optional k or m suffix.
+func parseCount(s string) (Limit, error) {
+	scale := uint64(1)
+	switch {
+	case strings.HasSuffix(s, "k"):
+		scale, s = 1e3, strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		scale, s = 1e6, strings.TrimSuffix(s, "m")
+	}
+
+	n, err := strconv.ParseUint(s, 10, 64)
+	switch {
+	case err != nil:
+		return 0, fmt.Errorf("count must be a number with an optional k or m suffix")
+	case n > (uint64(Infinite)-1)/scale:
+		return 0, fmt.Errorf("count is too large")
+	}
+
+	return Limit(n * scale), nil
+}
+
+func formatCount(limit Limit) string {
+	switch {
+	case limit == Infinite:
+		return "inf"
+	case limit != 0 && limit%1e6 == 0:
+		return strconv.FormatUint(uint64(limit/1e6), 10) + "m"
+	case limit != 0 && limit%1e3 == 0:
+		return strconv.FormatUint(uint64(limit/1e3), 10) + "k"
+	}
+
+	return strconv.FormatUint(uint64(limit), 10)
+}
@@ -0,0 +1,134 @@
+package limiter
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in    string
+		limit Limit
+		per   time.Duration
+		err   bool
+	}{
+		{in: "500/m", limit: 500, per: time.Minute},
+		{in: "10k/h", limit: 10000, per: time.Hour},
+		{in: "2m/d", limit: 2000000, per: 24 * time.Hour},
+		{in: "1/s", limit: 1, per: time.Second},
+		{in: "30/90s", limit: 30, per: 90 * time.Second},
+		{in: "7/1h30m", limit: 7, per: 90 * time.Minute},
+		{in: "inf", limit: Infinite},
+		{in: "infinite", limit: Infinite},
+		{in: "", err: true},
+		{in: "500", err: true},
+		{in: "0/m", err: true},
+		{in: "-1/m", err: true},
+		{in: "1.5k/m", err: true},
+		{in: "k/m", err: true},
+		{in: "10K/m", err: true},
+		{in: "10/w", err: true},
+		{in: "10/0s", err: true},
+		{in: "10/-1m", err: true},
+		{in: "10/", err: true},
+		{in: "18446744073709551615/s", err: true},
+		{in: "18446744073709552k/s", err: true},
+		{in: " 10/m", err: true},
+		{in: "inf/m", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			limit, per, err := ParseLimit(tt.in)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("parsed %d per %v, want an error", limit, per)
+				}
+				return
+			}
+			if err != nil || limit != tt.limit || per != tt.per {
+				t.Fatalf("got %d per %v, %v, want %d per %v", limit, per, err, tt.limit, tt.per)
+			}
+		})
+	}
+}
+
+func TestFormatLimit(t *testing.T) {
+	tests := []struct {
+		limit Limit
+		per   time.Duration
+		want  string
+	}{
+		{limit: 500, per: time.Minute, want: "500/m"},
+		{limit: 10000, per: time.Hour, want: "10k/h"},
+		{limit: 2000000, per: 24 * time.Hour, want: "2m/d"},
+		{limit: 1500, per: time.Second, want: "1500/s"},
+		{limit: 30, per: 90 * time.Second, want: "30/1m30s"},
+		{limit: Infinite, want: "inf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got := FormatLimit(tt.limit, tt.per)
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+
+			limit, per, err := ParseLimit(got)
+			if err != nil || limit != tt.limit || per != tt.per {
+				t.Fatalf("parsed back %d per %v, %v", limit, per, err)
+			}
+		})
+	}
+}
+
+func TestLimitText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Limit
+		out  string
+		err  bool
+	}{
+		{in: "0", want: 0, out: "0"},
+		{in: "42", want: 42, out: "42"},
+		{in: "3k", want: 3000, out: "3000"},
+		{in: "1m", want: 1000000, out: "1000000"},
+		{in: "inf", want: Infinite, out: "inf"},
+		{in: "infinite", want: Infinite, out: "inf"},
+		{in: "", err: true},
+		{in: "-1", err: true},
+		{in: "1/m", err: true},
+		{in: "18446744073709551615", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var l Limit
+			err := l.UnmarshalText([]byte(tt.in))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("unmarshaled %d, want an error", l)
+				}
+				return
+			}
+			if err != nil || l != tt.want {
+				t.Fatalf("got %d, %v, want %d", l, err, tt.want)
+			}
+
+			out, err := l.MarshalText()
+			if err != nil || string(out) != tt.out {
+				t.Fatalf("marshaled %q, %v, want %q", out, err, tt.out)
+			}
+		})
+	}
+
+	// Limit is a text value in JSON.
+	var v struct{ Limit Limit }
+	if err := json.Unmarshal([]byte(`{"Limit":"10k"}`), &v); err != nil || v.Limit != 10000 {
+		t.Fatalf("json got %d, %v, want 10000", v.Limit, err)
+	}
+	if out, err := json.Marshal(struct{ Limit Limit }{Infinite}); err != nil || string(out) != `{"Limit":"inf"}` {
+		t.Fatalf("json marshaled %s, %v", out, err)
+	}
+}
@@ -0,0 +1,74 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+type policyKind uint8
+
+const (
+	policyReject policyKind = iota
+	policyDelay
+	policyShed
+)
+
+// Policy defines what Allow does when the limit is saturated, see WithDenyPolicy.
+type Policy struct {
+	kind    policyKind
+	maxWait time.Duration
+	start   float64
+}
+
+// Reject returns the default policy: Allow returns false as soon as the limit is exceeded.
+func Reject() Policy {
+	return Policy{kind: policyReject}
+}
+
+// Delay returns a policy which makes Allow wait up to maxWait for a free unit
+// before returning false.
+func Delay(maxWait time.Duration) Policy {
+	return Policy{kind: policyDelay, maxWait: maxWait}
+}
+
+// Shed returns a policy which rejects a part of Allow calls once the usage passes
+// start fraction of the limit. The rejected part grows linearly from zero
+// at start to all calls at the limit. It panics if start is not in [0, 1).
+func Shed(start float64) Policy {
+	if !(start >= 0 && start < 1) {
+		panic("limiter: Shed start must be in [0, 1)")
+	}
+
+	return Policy{kind: policyShed, start: start}
+}
+
+// shedLocked decides whether the next Allow call should be shed.
+func (l *Limiter) shedLocked() bool {
+	budget := l.budgetLocked()
+	if l.policy.kind != policyShed || budget <= l.reserved || budget == Infinite {
+		return false
+	}
+
+	usage := float64(l.current) / float64(budget-l.reserved)
+	if usage <= l.policy.start {
+		return false
+	}
+
+	return l.random() < (usage-l.policy.start)/(1-l.policy.start)
+}
+
+// allowDelayed waits for a unit as the delay policy requires.
+func (l *Limiter) allowDelayed(n Limit) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), l.policy.maxWait)
+	defer cancel()
+
+	if _, err := l.acquire(ctx, "", n, 0, true); err == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	allow := l.denyLocked(n)
+	l.unlock()
+
+	return allow
+}
@@ -0,0 +1,96 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestWithEarlyShedCurve(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	l := New(WithRate(1000, time.Minute), WithEarlyShed(0.5), WithRandom(random.Float64), WithLazyReset())
+
+	for _, usage := range []float64{0.3, 0.5, 0.6, 0.75, 0.9, 1} {
+		l.lock()
+		l.current = Limit(usage * 1000)
+		shed := 0
+		const calls = 10000
+		for i := 0; i < calls; i++ {
+			if l.shedLocked() {
+				shed++
+			}
+		}
+		l.unlock()
+
+		want := math.Max(0, (usage-0.5)/0.5)
+		if got := float64(shed) / calls; math.Abs(got-want) > 0.02 {
+			t.Errorf("usage %.2f: shed %.3f of calls, want %.3f", usage, got, want)
+		}
+	}
+}
+
+func TestWithEarlyShedStats(t *testing.T) {
+	clock := newFakeClock()
+	random := rand.New(rand.NewSource(1))
+	l := New(WithRate(100, time.Minute), WithClock(clock), WithEarlyShed(0.5), WithRandom(random.Float64))
+	defer l.Close()
+
+	allowedN := allowed(l, 1000)
+	s := l.Stats()
+	if s.Allowed != uint64(allowedN) || s.Shed == 0 || s.Shed+s.Denied+s.Allowed != 1000 {
+		t.Fatalf("allowed %d, stats %+v", allowedN, s)
+	}
+
+	// Wait queues instead of being shed.
+	clock.Advance(time.Minute)
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		if !l.Wait(ctx) {
+			t.Fatal("Wait failed below the limit")
+		}
+	}
+	if got := l.Stats().Shed; got != s.Shed {
+		t.Fatalf("Wait was shed %d times", got-s.Shed)
+	}
+}
+
+func TestDelayPolicy(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(1, time.Minute), WithClock(clock), WithDenyPolicy(Delay(2*time.Minute)))
+	defer l.Close()
+
+	if !l.Allow() {
+		t.Fatal("denied below the limit")
+	}
+
+	// The window ends within maxWait, Allow waits for it.
+	done := make(chan bool)
+	go func() { done <- l.Allow() }()
+	eventually(t, func() bool { return l.Waiters() == 1 })
+	clock.Advance(time.Minute)
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Fatal("denied when the window ended within maxWait")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Allow didn't return after the window ended")
+	}
+
+	// The window ends after maxWait, Allow is denied without waiting.
+	short := New(WithRate(1, time.Minute), WithClock(clock), WithDenyPolicy(Delay(time.Second)))
+	defer short.Close()
+	short.Allow()
+	start := time.Now()
+	if short.Allow() {
+		t.Fatal("allowed when the window ends after maxWait")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Allow waited %v for a window which ends after maxWait", elapsed)
+	}
+	if s := short.Stats(); s.Allowed != 1 || s.Denied != 1 {
+		t.Fatalf("stats %+v, want 1 allowed and 1 denied", s)
+	}
+}
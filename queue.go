@@ -0,0 +1,159 @@
+package limiter
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// waiter is a goroutine blocked in Wait.
+type waiter struct {
+	key      string
+	n        Limit
+	priority int
+	ready    chan struct{}
+	granted  bool
+	// window is the window start when the waiter was granted.
+	window time.Time
+}
+
+// waitQueue keeps waiters in FIFO order per key
+// and hands out released units round-robin across keys which have waiters.
+// Waiters with a priority above zero are served before all of them.
+type waitQueue struct {
+	// urgent are the waiters with a priority, highest first.
+	urgent []*waiter
+	keys   []string
+	next   int
+	queues map[string][]*waiter
+	size   int
+}
+
+func (q *waitQueue) len() int {
+	return q.size
+}
+
+func (q *waitQueue) push(w *waiter) {
+	if w.priority > 0 {
+		i := sort.Search(len(q.urgent), func(i int) bool { return q.urgent[i].priority < w.priority })
+		q.urgent = append(q.urgent, nil)
+		copy(q.urgent[i+1:], q.urgent[i:])
+		q.urgent[i] = w
+		q.size++
+		return
+	}
+
+	if q.queues == nil {
+		q.queues = make(map[string][]*waiter)
+	}
+
+	if len(q.queues[w.key]) == 0 {
+		q.keys = append(q.keys, w.key)
+	}
+	q.queues[w.key] = append(q.queues[w.key], w)
+	q.size++
+}
+
+// peek returns the waiter which is served next.
+func (q *waitQueue) peek() *waiter {
+	if len(q.urgent) != 0 {
+		return q.urgent[0]
+	}
+	if len(q.keys) == 0 {
+		return nil
+	}
+
+	return q.queues[q.keys[q.next]][0]
+}
+
+// pop removes the waiter returned by peek and moves to the next key.
+func (q *waitQueue) pop() *waiter {
+	w := q.peek()
+	if w == nil {
+		return nil
+	}
+	if len(q.urgent) != 0 {
+		q.urgent = q.urgent[1:]
+		q.size--
+		return w
+	}
+
+	key := q.keys[q.next]
+	q.queues[key] = q.queues[key][1:]
+	q.size--
+	if len(q.queues[key]) == 0 {
+		q.removeKey(q.next)
+	} else {
+		q.next = (q.next + 1) % len(q.keys)
+	}
+
+	return w
+}
+
+// remove deletes a waiter which gave up waiting.
+func (q *waitQueue) remove(w *waiter) {
+	for i := range q.urgent {
+		if q.urgent[i] == w {
+			q.urgent = append(q.urgent[:i:i], q.urgent[i+1:]...)
+			q.size--
+			return
+		}
+	}
+
+	queue := q.queues[w.key]
+	for i := range queue {
+		if queue[i] != w {
+			continue
+		}
+
+		q.queues[w.key] = append(queue[:i:i], queue[i+1:]...)
+		q.size--
+		if len(q.queues[w.key]) == 0 {
+			for j := range q.keys {
+				if q.keys[j] == w.key {
+					q.removeKey(j)
+					break
+				}
+			}
+		}
+
+		return
+	}
+}
+
+func (q *waitQueue) removeKey(i int) {
+	delete(q.queues, q.keys[i])
+	q.keys = append(q.keys[:i], q.keys[i+1:]...)
+
+	switch {
+	case i < q.next:
+		q.next--
+	case q.next >= len(q.keys):
+		q.next = 0
+	}
+}
+
+// Waiters returns the number of goroutines blocked in Wait.
+func (l *Limiter) Waiters() int {
+	l.checkNew()
+	if n, ok := l.loadFresh(l.now(), func(o *observed) uint64 { return uint64(atomic.LoadInt64(&o.waiters)) }); ok {
+		return int(n)
+	}
+
+	l.lock()
+	defer l.unlock()
+
+	return l.waiters.len()
+}
+
+// grantWaitersLocked hands out available units to waiters.
+// The next waiter blocks the others until its units are available.
+func (l *Limiter) grantWaitersLocked(now time.Time) {
+	for w := l.waiters.peek(); w != nil && l.availableLocked(w.n, l.reserved); w = l.waiters.peek() {
+		l.waiters.pop()
+		l.consumeLocked(w.n, now)
+		w.granted = true
+		w.window = l.windowStart
+		close(w.ready)
+	}
+}
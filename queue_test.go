@@ -0,0 +1,180 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitQueueRoundRobin(t *testing.T) {
+	var q waitQueue
+	for _, key := range []string{"a", "a", "a", "a", "b", "c", "c"} {
+		q.push(&waiter{key: key})
+	}
+	q.push(&waiter{key: "a", priority: 1})
+
+	var order string
+	for w := q.pop(); w != nil; w = q.pop() {
+		if w.priority > 0 {
+			order += "!"
+		}
+		order += w.key
+	}
+
+	if want := "!aabcacaa"; order != want {
+		t.Fatalf("served %q, want %q", order, want)
+	}
+	if q.len() != 0 {
+		t.Fatalf("len %d after draining", q.len())
+	}
+}
+
+func TestWaitKeyedFairness(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(4, time.Minute), WithClock(clock))
+	defer l.Close()
+
+	allowed(l, 4)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	granted := make(chan string)
+	wait := func(key string) {
+		if l.WaitKeyed(ctx, key) {
+			granted <- key
+		}
+	}
+	for i := 0; i < 20; i++ {
+		go wait("heavy")
+	}
+	for i := 0; i < 2; i++ {
+		go wait("light")
+	}
+	eventually(t, func() bool { return l.Waiters() == 22 })
+
+	clock.Advance(time.Minute)
+
+	count := map[string]int{}
+	for i := 0; i < 4; i++ {
+		count[<-granted]++
+	}
+	if count["light"] != 2 || count["heavy"] != 2 {
+		t.Fatalf("granted %v, want the units split evenly between the keys", count)
+	}
+}
+
+func TestWithMaxWaiters(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(3, time.Minute), WithClock(clock), WithMaxWaiters(3))
+	defer l.Close()
+
+	allowed(l, 3)
+
+	ctx := context.Background()
+	errs := make(chan error)
+	for i := 0; i < 3; i++ {
+		go func() { errs <- l.WaitErr(ctx) }()
+	}
+	eventually(t, func() bool { return l.Waiters() == 3 })
+
+	if err := l.WaitErr(ctx); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("overflow waiter got %v, want ErrQueueFull", err)
+	}
+
+	// The reset drains the queue.
+	clock.Advance(time.Minute)
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("queued waiter got %v", err)
+		}
+	}
+	if n := l.Waiters(); n != 0 {
+		t.Fatalf("%d waiters after draining", n)
+	}
+
+	go func() { errs <- l.WaitErr(ctx) }()
+	eventually(t, func() bool { return l.Waiters() == 1 })
+	clock.Advance(time.Minute)
+	if err := <-errs; err != nil {
+		t.Fatalf("waiter queued after draining got %v", err)
+	}
+}
+
+func TestWaitersGauge(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(10, time.Minute), WithClock(clock))
+	defer l.Close()
+
+	allowed(l, 10)
+
+	const waiters = 200
+	cancels := make([]context.CancelFunc, waiters)
+	results := make(chan bool, waiters)
+	for i := range cancels {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancels[i] = cancel
+		go func() { results <- l.Wait(ctx) }()
+	}
+	eventually(t, func() bool { return l.Waiters() == waiters })
+
+	for i := 0; i < waiters; i += 2 {
+		cancels[i]()
+	}
+	eventually(t, func() bool { return l.Waiters() == waiters/2 })
+
+	for i := 0; i < waiters/2/10; i++ {
+		clock.Advance(time.Minute)
+		eventually(t, func() bool { return len(results) >= waiters/2+(i+1)*10 })
+	}
+
+	granted := 0
+	for i := 0; i < waiters; i++ {
+		if <-results {
+			granted++
+		}
+	}
+	if granted != waiters/2 {
+		t.Fatalf("granted %d, want %d", granted, waiters/2)
+	}
+	if l.Waiters() != 0 || l.Stats().Waiters != 0 || l.State().Waiters != 0 {
+		t.Fatalf("waiters %d, stats %d, state %d after the load", l.Waiters(), l.Stats().Waiters, l.State().Waiters)
+	}
+	for _, cancel := range cancels {
+		cancel()
+	}
+}
+
+func TestWaitPriority(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(1, time.Minute), WithClock(clock))
+	defer l.Close()
+
+	l.Allow()
+	served := make(chan string, 4)
+	for i, w := range []struct {
+		name     string
+		priority int
+	}{{"regular", 0}, {"regular after", 0}, {"urgent", 1}, {"most urgent", 2}} {
+		go func(name string, priority int) {
+			if l.WaitPriority(context.Background(), priority) {
+				served <- name
+			}
+		}(w.name, w.priority)
+		eventually(t, func() bool { return l.Waiters() == i+1 })
+	}
+
+	// A unit per window, the urgent waiters go first and the same priority keeps FIFO order.
+	for _, want := range []string{"most urgent", "urgent", "regular", "regular after"} {
+		clock.Advance(time.Minute)
+		select {
+		case got := <-served:
+			if got != want {
+				t.Fatalf("served %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%q isn't served", want)
+		}
+	}
+}
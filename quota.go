@@ -0,0 +1,49 @@
+package limiter
+
+import "time"
+
+// quota is an additional fixed window counted together with the main limit.
+type quota struct {
+	limit    Limit
+	interval time.Duration
+	current  Limit
+	start    time.Time
+}
+
+func (q *quota) advance(now time.Time) {
+	if elapsed := now.Sub(q.start); elapsed >= q.interval {
+		q.start = q.start.Add(elapsed / q.interval * q.interval)
+		q.current = 0
+	}
+}
+
+func (q *quota) available(n Limit) bool {
+	return q.current < q.limit && n <= q.limit-q.current
+}
+
+// remaining returns the units left in the quota window.
+func (q *quota) remaining() Limit {
+	if q.current >= q.limit {
+		return 0
+	}
+
+	return q.limit - q.current
+}
+
+// nextEvent returns the end of the quota window if there is usage to reset.
+func (q *quota) nextEvent() time.Time {
+	if q.current == 0 {
+		return time.Time{}
+	}
+
+	return q.start.Add(q.interval)
+}
+
+// earliest returns the earliest of the times, ignoring zero times.
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || !b.IsZero() && b.Before(a) {
+		return b
+	}
+
+	return a
+}
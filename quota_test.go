@@ -0,0 +1,47 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithSubLimit(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(5, time.Hour), WithSubLimit(2, time.Second), WithClock(clock))
+	defer l.Close()
+
+	// The short window spaces the main one.
+	if got := allowed(l, 5); got != 2 {
+		t.Fatalf("allowed %d in the first second, want 2", got)
+	}
+	if got := l.Remaining(); got != 0 {
+		t.Fatalf("remaining %d, want the tightest window", got)
+	}
+	clock.Advance(time.Second)
+	if got := allowed(l, 5); got != 2 {
+		t.Fatalf("allowed %d in the second second, want 2", got)
+	}
+
+	// Wait blocks until both windows allow.
+	clock.Advance(time.Second)
+	allowed(l, 1)
+	done := make(chan bool)
+	go func() { done <- l.Wait(context.Background()) }()
+	eventually(t, func() bool { return l.Waiters() == 1 })
+	clock.Advance(time.Second)
+	select {
+	case <-done:
+		t.Fatal("Wait returned with the main window exhausted")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(time.Hour)
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Fatal("Wait failed after both windows reset")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait didn't return after both windows reset")
+	}
+}
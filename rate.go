@@ -0,0 +1,92 @@
+package limiter
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// If you don't send option the WithRateHorizon, Rate will use this horizon.
+const defaultRateHorizon = time.Minute
+
+const rateSlots = 60
+
+// rateMeter counts consumed units in a ring of time slots which cover the horizon.
+// It uses atomics only, so Rate doesn't take the limiter lock.
+type rateMeter struct {
+	base  time.Time
+	width time.Duration
+	slots [rateSlots]rateSlot
+}
+
+type rateSlot struct {
+	epoch int64
+	count uint64
+}
+
+func newRateMeter(horizon time.Duration, now time.Time) *rateMeter {
+	width := horizon / rateSlots
+	if width <= 0 {
+		width = 1
+	}
+
+	return &rateMeter{base: now, width: width}
+}
+
+func (m *rateMeter) add(now time.Time, n uint64) {
+	epoch := int64(now.Sub(m.base) / m.width)
+	i := epoch % rateSlots
+	if i < 0 {
+		// AllowAt can replay events before the meter was created.
+		i += rateSlots
+	}
+	s := &m.slots[i]
+
+	if old := atomic.LoadInt64(&s.epoch); old != epoch {
+		if atomic.CompareAndSwapInt64(&s.epoch, old, epoch) {
+			atomic.StoreUint64(&s.count, 0)
+		}
+	}
+	atomic.AddUint64(&s.count, n)
+}
+
+// remove uncounts n units added in the slot of now, the units of older slots are kept.
+func (m *rateMeter) remove(now time.Time, n uint64) {
+	epoch := int64(now.Sub(m.base) / m.width)
+	i := epoch % rateSlots
+	if i < 0 {
+		i += rateSlots
+	}
+	s := &m.slots[i]
+
+	for atomic.LoadInt64(&s.epoch) == epoch {
+		count := atomic.LoadUint64(&s.count)
+		if count < n {
+			n = count
+		}
+		if atomic.CompareAndSwapUint64(&s.count, count, count-n) {
+			return
+		}
+	}
+}
+
+// rate returns events per second over the horizon before now.
+func (m *rateMeter) rate(now time.Time) float64 {
+	epoch := int64(now.Sub(m.base) / m.width)
+
+	var sum uint64
+	for i := range m.slots {
+		s := &m.slots[i]
+		if e := atomic.LoadInt64(&s.epoch); e > epoch-rateSlots && e <= epoch {
+			sum += atomic.LoadUint64(&s.count)
+		}
+	}
+
+	return float64(sum) / (m.width * rateSlots).Seconds()
+}
+
+// Rate returns the observed number of consumed units per second over the recent past,
+// see WithRateHorizon. It decays to zero when traffic stops.
+func (l *Limiter) Rate() float64 {
+	l.checkNew()
+	return l.meter.rate(l.now())
+}
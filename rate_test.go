@@ -0,0 +1,34 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRate(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(40, time.Hour), WithClock(clock), WithRateHorizon(time.Minute))
+	defer l.Close()
+
+	if got := l.Rate(); got != 0 {
+		t.Fatalf("rate %v before any call, want 0", got)
+	}
+
+	// The denied calls aren't counted.
+	allowed(l, 30)
+	clock.Advance(30 * time.Second)
+	allowed(l, 30)
+	if got := l.Rate(); got != 40.0/60 {
+		t.Fatalf("rate %v, want 40 units over the minute", got)
+	}
+
+	// The units older than the horizon decay.
+	clock.Advance(40 * time.Second)
+	if got := l.Rate(); got != 10.0/60 {
+		t.Fatalf("rate %v after the first units left the horizon, want 10 units over the minute", got)
+	}
+	clock.Advance(time.Minute)
+	if got := l.Rate(); got != 0 {
+		t.Fatalf("rate %v after the horizon, want 0", got)
+	}
+}
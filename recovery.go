@@ -0,0 +1,123 @@
+package limiter
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// recoveryRate returns how many units gradual recovery restores per period.
+// With a step, see WithGradualRecovery, they are restored at once at the end of the period,
+// otherwise they are spread over it, so the whole amount is restored exactly by its end.
+// In token bucket mode the refill rate is used instead of the limit.
+// An infinite amount can't be spread, it is restored at once every period.
+func (l *Limiter) recoveryRate() (units Limit, per time.Duration, step bool) {
+	switch {
+	case l.recoveryStep != 0:
+		return l.recoveryStep, l.recoveryEvery, true
+	case l.refill != 0:
+		return l.refill, l.interval, l.refill == Infinite
+	}
+
+	return l.limit, l.interval, l.limit == Infinite
+}
+
+// restartRecoveryLocked starts restoring units step by step from origin.
+func (l *Limiter) restartRecoveryLocked(origin time.Time) {
+	l.recoveryOrigin = origin
+	l.recovered = 0
+	l.nextStep = l.stepAtLocked(1)
+}
+
+// recoverLocked restores the units due by now and schedules the next step,
+// if anything is still used.
+func (l *Limiter) recoverLocked(now time.Time) {
+	units, per, step := l.recoveryRate()
+	elapsed := now.Sub(l.recoveryOrigin)
+	if elapsed < 0 {
+		return
+	}
+
+	var restore Limit
+	if periods := elapsed / per; periods > 0 {
+		// Whole periods restore exactly units each, the origin follows them.
+		restore = mulLimit(Limit(periods), units)
+		if restore != Infinite {
+			restore -= l.recovered
+		}
+		l.recoveryOrigin = l.recoveryOrigin.Add(periods * per)
+		l.recovered = 0
+		elapsed -= periods * per
+	}
+
+	if !step {
+		part := mulDiv(units, Limit(elapsed), Limit(per))
+		restore = addLimit(restore, part-l.recovered)
+		l.recovered = part
+	}
+
+	if l.current < restore {
+		l.current = 0
+	} else {
+		l.current -= restore
+	}
+
+	// Nothing is left to restore, consuming arms the steps again, see holdLocked.
+	l.nextStep = time.Time{}
+	if l.current != 0 {
+		l.nextStep = l.stepAtLocked(l.recovered + 1)
+	}
+}
+
+// stepAtLocked returns when n units are restored since the recovery origin,
+// the zero time if never.
+func (l *Limiter) stepAtLocked(n Limit) time.Time {
+	offset := l.recoveryOffset(n)
+	if offset == math.MaxInt64 {
+		return time.Time{}
+	}
+
+	return l.recoveryOrigin.Add(offset)
+}
+
+// recoveryOffset returns how long after the recovery origin n units are restored,
+// math.MaxInt64 if never.
+func (l *Limiter) recoveryOffset(n Limit) time.Duration {
+	units, per, step := l.recoveryRate()
+	if units == 0 {
+		return math.MaxInt64
+	}
+
+	if step {
+		periods := (n-1)/units + 1
+		if periods > Limit(math.MaxInt64/per) {
+			return math.MaxInt64
+		}
+
+		return time.Duration(periods) * per
+	}
+
+	offset := Limit(math.MaxInt64)
+	if hi, lo := bits.Mul64(uint64(n), uint64(per)); hi < uint64(units) {
+		q, r := bits.Div64(hi, lo, uint64(units))
+		if r != 0 {
+			q++
+		}
+		if q < uint64(offset) {
+			offset = Limit(q)
+		}
+	}
+
+	return time.Duration(offset)
+}
+
+// mulDiv returns a*b/c rounded down, saturating at Infinite.
+func mulDiv(a, b, c Limit) Limit {
+	hi, lo := bits.Mul64(uint64(a), uint64(b))
+	if hi >= uint64(c) {
+		return Infinite
+	}
+	q, _ := bits.Div64(hi, lo, uint64(c))
+
+	return Limit(q)
+}
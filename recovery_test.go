@@ -0,0 +1,170 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGradualRecoveryIdle(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(10, 10*time.Second), WithClock(clock), WithGradualRecovery(0, 0))
+	defer l.Close()
+
+	if got := allowed(l, 3); got != 3 {
+		t.Fatalf("allowed %d, want 3", got)
+	}
+	clock.Advance(3 * time.Second)
+
+	// Fully recovered, the limiter only wakes up when the window rolls over.
+	now := l.lock()
+	step, next, end := l.nextStep, l.nextEventLocked(), l.windowStart.Add(l.interval)
+	l.unlock()
+	if !step.IsZero() || !next.Equal(end) {
+		t.Fatalf("idle limiter waits for the step %v and the event %v, want none and %v", step, next, end)
+	}
+
+	// Consuming starts the steps from now.
+	if got := allowed(l, 2); got != 2 {
+		t.Fatalf("allowed %d after idling, want 2", got)
+	}
+	l.lock()
+	step = l.nextStep
+	l.unlock()
+	if want := now.Add(time.Second); !step.Equal(want) {
+		t.Fatalf("next step at %v after consuming, want %v", step, want)
+	}
+
+	clock.Advance(time.Second)
+	if used := l.Used(); used != 1 {
+		t.Fatalf("used %d a step after consuming, want 1", used)
+	}
+	clock.Advance(time.Second)
+	if used := l.Used(); used != 0 {
+		t.Fatalf("used %d two steps after consuming, want 0", used)
+	}
+	l.lock()
+	step = l.nextStep
+	l.unlock()
+	if !step.IsZero() {
+		t.Fatalf("recovered limiter waits for the step %v, want none", step)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	newBucket := func(clock *fakeClock) *Limiter {
+		// A unit is refilled every second, up to 5.
+		return New(WithInterval(10*time.Second), WithTokenBucket(10, 5), WithClock(clock))
+	}
+
+	t.Run("refill", func(t *testing.T) {
+		clock := newFakeClock()
+		l := newBucket(clock)
+		defer l.Close()
+
+		if got := allowed(l, 6); got != 5 {
+			t.Fatalf("allowed %d from a full bucket, want 5", got)
+		}
+		clock.Advance(time.Second)
+		if got := allowed(l, 2); got != 1 {
+			t.Fatalf("allowed %d a second later, want 1", got)
+		}
+		clock.Advance(2500 * time.Millisecond)
+		if got := allowed(l, 3); got != 2 {
+			t.Fatalf("allowed %d 2.5 seconds later, want 2", got)
+		}
+	})
+
+	t.Run("burst cap", func(t *testing.T) {
+		clock := newFakeClock()
+		l := newBucket(clock)
+		defer l.Close()
+
+		allowed(l, 5)
+		clock.Advance(time.Minute)
+		if got := allowed(l, 10); got != 5 {
+			t.Fatalf("allowed %d after a minute, want the burst of 5", got)
+		}
+	})
+
+	t.Run("idle", func(t *testing.T) {
+		clock := newFakeClock()
+		l := newBucket(clock)
+		defer l.Close()
+
+		allowed(l, 2)
+		advance := func(n int) {
+			for i := 0; i < n; i++ {
+				clock.Advance(time.Second)
+				// Let the cleanup goroutine arm its next timer.
+				eventually(t, func() bool {
+					_, pending := clock.wakeups()
+					return pending > 0
+				})
+			}
+		}
+		advance(5)
+		if r := l.Remaining(); r != 5 {
+			t.Fatalf("remaining %d after refilling, want 5", r)
+		}
+
+		// The full bucket wakes up only when the window rolls over, at 10s and 20s.
+		before, _ := clock.wakeups()
+		advance(20)
+		if fired, _ := clock.wakeups(); fired-before > 2 {
+			t.Fatalf("idle bucket woke up %d times in 20 seconds, want at most 2", fired-before)
+		}
+		if r := l.Remaining(); r != 5 {
+			t.Fatalf("remaining %d after idling, want 5", r)
+		}
+	})
+}
+
+func TestGradualRecoveryCatchUp(t *testing.T) {
+	tests := []struct {
+		name string
+		// ago is how long before New the restored window started.
+		ago      time.Duration
+		used     Limit
+		caughtUp Limit
+		windowID uint64
+		// next is when the next step restores two units.
+		next time.Duration
+	}{
+		{name: "before the first step", ago: 2 * time.Second, used: 15, caughtUp: 0, windowID: 4, next: time.Second},
+		{name: "several steps", ago: 10 * time.Second, used: 9, caughtUp: 6, windowID: 4, next: 2 * time.Second},
+		{name: "reset", ago: 70 * time.Second, used: 0, caughtUp: 15, windowID: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			l := New(WithRate(20, time.Minute), WithGradualRecovery(2, 3*time.Second), WithClock(clock),
+				WithState(State{Used: 15, WindowStart: clock.Now().Add(-tt.ago), WindowID: 4}))
+			defer l.Close()
+
+			// The steps and resets due since the window start are applied before the first call.
+			if got := l.Used(); got != tt.used {
+				t.Fatalf("used %d after New, want %d", got, tt.used)
+			}
+			if got := l.Stats().CaughtUp; got != tt.caughtUp {
+				t.Fatalf("caught up %d, want %d", got, tt.caughtUp)
+			}
+			if got := l.WindowID(); got != tt.windowID {
+				t.Fatalf("window %d, want %d", got, tt.windowID)
+			}
+
+			// The recovery goes on from the restored window start.
+			if tt.next == 0 {
+				return
+			}
+			clock.Advance(tt.next - time.Millisecond)
+			if got := l.Used(); got != tt.used {
+				t.Fatalf("used %d before the next step, want %d", got, tt.used)
+			}
+			clock.Advance(time.Millisecond)
+			if got := l.Used(); got != tt.used-2 {
+				t.Fatalf("used %d after the next step, want %d", got, tt.used-2)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package limiter
+
+// AddCapacity adds n units to a limiter with external refill and wakes blocked waiters.
+// The capacity above the limit is dropped, the added part is counted in Stats.Granted.
+// It panics if the limiter doesn't use WithExternalRefill.
+func (l *Limiter) AddCapacity(n Limit) {
+	l.checkNew()
+	if !l.external {
+		panic("limiter: AddCapacity requires external refill")
+	}
+
+	now := l.lock()
+	defer l.unlock()
+
+	if n > l.current {
+		n = l.current
+	}
+	l.current -= n
+	l.stats.Granted += uint64(n)
+	l.grantWaitersLocked(now)
+}
@@ -0,0 +1,52 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAddCapacity(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(5, time.Minute), WithClock(clock), WithExternalRefill())
+	defer l.Close()
+
+	if l.Allow() || l.RetryAfter() != Never {
+		t.Fatal("an empty limiter allowed or knows when to retry")
+	}
+
+	first, second := make(chan bool), make(chan bool)
+	go func() { first <- l.WaitN(context.Background(), 2) }()
+	eventually(t, func() bool { return l.Waiters() == 1 })
+	go func() { second <- l.Wait(context.Background()) }()
+	eventually(t, func() bool { return l.Waiters() == 2 })
+
+	// The added units wake the waiters in order.
+	l.AddCapacity(2)
+	if !<-first {
+		t.Fatal("the first waiter failed after AddCapacity")
+	}
+	select {
+	case <-second:
+		t.Fatal("the second waiter got a unit taken by the first")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// The capacity above the limit is dropped.
+	l.AddCapacity(10)
+	if !<-second {
+		t.Fatal("the second waiter failed after AddCapacity")
+	}
+	if got := l.Remaining(); got != 4 {
+		t.Fatalf("remaining %d, want 4 of the limit", got)
+	}
+	if got := l.Stats().Granted; got != 7 {
+		t.Fatalf("granted %d, want 7", got)
+	}
+
+	// The capacity doesn't reset.
+	clock.Advance(time.Hour)
+	if got := l.Remaining(); got != 4 {
+		t.Fatalf("remaining %d after an hour, want 4", got)
+	}
+}
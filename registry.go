@@ -0,0 +1,71 @@
+package limiter
+
+import (
+	"sort"
+	"sync"
+)
+
+// Registry keeps named limiters and creates them on first use.
+type Registry struct {
+	mu       sync.Mutex
+	defaults []Option
+	limiters map[string]*Limiter
+}
+
+// NewRegistry build and returns new instance Registry.
+// The defaults are applied to every limiter before options passed to Get.
+func NewRegistry(defaults ...Option) *Registry {
+	return &Registry{
+		mu:       sync.Mutex{},
+		defaults: defaults,
+		limiters: make(map[string]*Limiter),
+	}
+}
+
+// Get returns the limiter with the name, creating it with the default options
+// and opts if it doesn't exist. The options are ignored for an existing limiter.
+func (r *Registry) Get(name string, opts ...Option) *Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if l, ok := r.limiters[name]; ok {
+		return l
+	}
+
+	all := make([]Option, 0, len(r.defaults)+len(opts)+1)
+	all = append(all, r.defaults...)
+	all = append(all, opts...)
+	all = append(all, WithName(name))
+
+	l := New(all...)
+	r.limiters[name] = l
+
+	return l
+}
+
+// Names returns sorted names of the registered limiters.
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.limiters))
+	for name := range r.limiters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// CloseAll closes and removes all registered limiters.
+// Waiters blocked on them return false.
+func (r *Registry) CloseAll() {
+	r.mu.Lock()
+	limiters := r.limiters
+	r.limiters = make(map[string]*Limiter)
+	r.mu.Unlock()
+
+	for _, l := range limiters {
+		l.Close()
+	}
+}
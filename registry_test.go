@@ -0,0 +1,76 @@
+package limiter
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(WithRate(5, time.Minute))
+
+	const workers = 16
+	got := make([][]*Limiter, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 4; i++ {
+				got[w] = append(got[w], r.Get("l"+strconv.Itoa(i), WithRate(uint64(i+1), time.Minute)))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Every caller gets the same limiter, created once with the options of the first one.
+	for w := 1; w < workers; w++ {
+		for i := range got[w] {
+			if got[w][i] != got[0][i] {
+				t.Fatalf("worker %d got another limiter l%d", w, i)
+			}
+		}
+	}
+	if l := got[0][2]; l.Name() != "l2" || l.Limit() != 3 {
+		t.Fatalf("limiter %q with limit %d, want l2 with 3", l.Name(), l.Limit())
+	}
+	if names := r.Names(); !reflect.DeepEqual(names, []string{"l0", "l1", "l2", "l3"}) {
+		t.Fatalf("names %v", names)
+	}
+}
+
+func TestRegistryCloseAll(t *testing.T) {
+	r := NewRegistry(WithRate(1, time.Hour))
+	l := r.Get("a")
+	l.Allow()
+
+	done := make(chan bool)
+	go func() { done <- l.Wait(context.Background()) }()
+	eventually(t, func() bool { return l.Waiters() == 1 })
+
+	// Get races with CloseAll, it returns either the closed limiter or a new one.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Get("a")
+		}()
+	}
+	r.CloseAll()
+	wg.Wait()
+
+	if <-done {
+		t.Fatal("a waiter succeeded after CloseAll")
+	}
+	if next := r.Get("a"); next == l || !next.Allow() {
+		t.Fatal("Get after CloseAll returned the closed limiter")
+	}
+	r.CloseAll()
+	if names := r.Names(); len(names) != 0 {
+		t.Fatalf("names %v after CloseAll", names)
+	}
+}
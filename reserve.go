@@ -0,0 +1,122 @@
+package limiter
+
+import "time"
+
+// Reservation holds units until they are available, see Limiter.Reserve.
+type Reservation struct {
+	l  *Limiter
+	n  Limit
+	ok bool
+	at time.Time
+	// window is the window start when the units were taken at once.
+	window time.Time
+	// w is the queued waiter, nil if the units were taken at once.
+	w *waiter
+	// held is false if nothing was taken, e.g. in shadow mode.
+	held     bool
+	canceled bool
+}
+
+// Reserve is the same as ReserveN for one unit.
+func (l *Limiter) Reserve() *Reservation {
+	return l.ReserveN(1)
+}
+
+// ReserveN reserves n units and reports how long the caller has to wait before using them.
+// If they aren't available, the reservation is queued with the waiters and the units are
+// consumed once they are granted. Use Cancel to give them back if the action isn't taken.
+// The reservation is not OK if the limiter is closed, a filter denies it, the wait queue is full
+// or n units can never be available. The delay is an estimate when other waiters are queued.
+func (l *Limiter) ReserveN(n Limit) *Reservation {
+	l.checkNew()
+	r := &Reservation{l: l, n: n}
+	if l.filtered() {
+		return r
+	}
+
+	l.mu.Lock()
+	defer l.unlock()
+
+	now := l.now()
+	l.advanceLocked(now)
+	switch {
+	case l.closed:
+		return r
+	case l.shadow:
+		l.allowLocked(n, l.reserved, false, now)
+		r.ok, r.at = true, now
+		return r
+	case l.waiters.peek() == nil && l.takeLocked(n, l.reserved, now):
+		r.ok, r.at, r.held, r.window = true, now, true, l.windowStart
+		return r
+	case l.maxWaiters >= 0 && l.waiters.len() >= l.maxWaiters:
+		return r
+	}
+
+	at, ok := l.availableAtLocked(n, l.reserved, now)
+	if !ok {
+		return r
+	}
+
+	r.w = &waiter{n: n, ready: make(chan struct{})}
+	l.waiters.push(r.w)
+	r.ok, r.at, r.held = true, at, true
+
+	return r
+}
+
+// OK reports whether the units are reserved.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay returns how long the caller has to wait before using the units,
+// Never if the reservation is not OK.
+func (r *Reservation) Delay() time.Duration {
+	if !r.ok {
+		return Never
+	}
+	if d := r.at.Sub(r.l.now()); d > 0 {
+		return d
+	}
+
+	return 0
+}
+
+// Cancel gives the reserved units back, they are not counted in Stats and Rate.
+// Like a Token rolled back late, units taken in a window which has reset since
+// are not given back, the new window has its own limit.
+// It does nothing if the reservation is not OK or already canceled.
+func (r *Reservation) Cancel() {
+	if !r.ok || !r.held {
+		return
+	}
+
+	l := r.l.lockOwner()
+	defer l.unlock()
+
+	if r.canceled {
+		return
+	}
+	r.canceled = true
+
+	now := l.now()
+	l.advanceLocked(now)
+	if r.w != nil && !r.w.granted {
+		l.waiters.remove(r.w)
+		l.grantWaitersLocked(now)
+		return
+	}
+
+	window := r.window
+	if r.w != nil {
+		window = r.w.window
+	}
+	if !l.windowStart.Equal(window) {
+		return
+	}
+
+	l.releaseLocked(r.n)
+	l.stats.Allowed -= uint64(r.n)
+	l.meter.remove(now, uint64(r.n))
+}
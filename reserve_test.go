@@ -0,0 +1,103 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReserve(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(2, time.Minute), WithClock(clock))
+	defer l.Close()
+
+	r := l.Reserve()
+	if !r.OK() || r.Delay() != 0 {
+		t.Fatalf("ok %t, delay %v, want an available unit", r.OK(), r.Delay())
+	}
+	rate := l.Rate()
+	r.Cancel()
+	if got := l.Stats().Allowed; got != 0 {
+		t.Fatalf("allowed %d after Cancel, want 0", got)
+	}
+	if got := l.Rate(); got >= rate {
+		t.Fatalf("rate %v after Cancel, want below %v", got, rate)
+	}
+	if got := allowed(l, 3); got != 2 {
+		t.Fatalf("allowed %d after Cancel, want 2", got)
+	}
+	// A second Cancel gives nothing back.
+	r.Cancel()
+	if l.Allow() {
+		t.Fatal("allowed after a second Cancel")
+	}
+
+	// An exhausted limiter queues the reservation until the reset.
+	clock.Advance(20 * time.Second)
+	queued := l.Reserve()
+	if !queued.OK() || queued.Delay() != 40*time.Second || l.Waiters() != 1 {
+		t.Fatalf("ok %t, delay %v, waiters %d, want a queued reservation for 40s",
+			queued.OK(), queued.Delay(), l.Waiters())
+	}
+	clock.Advance(30 * time.Second)
+	if got := queued.Delay(); got != 10*time.Second {
+		t.Fatalf("delay %v, want 10s", got)
+	}
+	queued.Cancel()
+	if l.Waiters() != 0 {
+		t.Fatal("the canceled reservation is still queued")
+	}
+	clock.Advance(10 * time.Second)
+	if got := allowed(l, 3); got != 2 {
+		t.Fatalf("allowed %d after the canceled reservation, want 2", got)
+	}
+}
+
+func TestReserveCancelAfterReset(t *testing.T) {
+	tests := []struct {
+		name string
+		// exhaust makes the reservation wait for the reset.
+		exhaust bool
+	}{
+		{name: "taken at once"},
+		{name: "granted at the reset", exhaust: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			l := New(WithRate(2, time.Minute), WithClock(clock))
+			defer l.Close()
+
+			if tt.exhaust {
+				allowed(l, 2)
+			}
+			r := l.Reserve()
+			clock.Advance(time.Minute)
+			if tt.exhaust {
+				eventually(t, func() bool { return l.Waiters() == 0 })
+				clock.Advance(time.Minute)
+			}
+
+			// The units of a reset window aren't given to the new one.
+			allowed(l, 2)
+			r.Cancel()
+			if l.Allow() {
+				t.Fatal("allowed above the limit after canceling an old reservation")
+			}
+		})
+	}
+}
+
+func TestReserveNotOK(t *testing.T) {
+	l := New(WithRate(2, time.Minute), WithClock(newFakeClock()))
+	if r := l.ReserveN(3); r.OK() || r.Delay() != Never {
+		t.Fatalf("ok %t, delay %v for more than the limit", r.OK(), r.Delay())
+	}
+
+	l.Close()
+	r := l.Reserve()
+	if r.OK() || r.Delay() != Never {
+		t.Fatalf("ok %t, delay %v after Close", r.OK(), r.Delay())
+	}
+	r.Cancel()
+}
@@ -0,0 +1,183 @@
+package limiter
+
+import (
+	"math"
+	"time"
+)
+
+// Never is returned by RetryAfter when the requested units can't become available,
+// for example when the limit is zero.
+const Never time.Duration = math.MaxInt64
+
+// RetryAfter returns how long until Allow can succeed, zero if it can succeed now.
+// It accounts for resets and recovery steps only, units consumed by others
+// in the meantime can make the real wait longer.
+func (l *Limiter) RetryAfter() time.Duration {
+	return l.RetryAfterN(1)
+}
+
+// RetryAfterN is the same as RetryAfter for AllowN of n units.
+func (l *Limiter) RetryAfterN(n Limit) time.Duration {
+	l.checkNew()
+	l.mu.Lock()
+	defer l.unlock()
+
+	now := l.now()
+	l.advanceLocked(now)
+
+	return l.retryAfterLocked(n, l.reserved, now)
+}
+
+// retryAfterLocked returns how long until n units are available without the reserved units.
+func (l *Limiter) retryAfterLocked(n, reserved Limit, now time.Time) time.Duration {
+	at, ok := l.availableAtLocked(n, reserved, now)
+	if !ok {
+		return Never
+	}
+	if at.Before(now) {
+		return 0
+	}
+
+	return at.Sub(now)
+}
+
+// availableAtLocked returns the earliest time when n units are available
+// without the reserved units, if nothing else is consumed meanwhile.
+func (l *Limiter) availableAtLocked(n, reserved Limit, now time.Time) (time.Time, bool) {
+	at, ok := l.windowAvailableAtLocked(n, reserved, now)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	if !l.spacedLocked() && l.nextGrant.After(at) {
+		at = l.nextGrant
+	}
+
+	for i := range l.quotas {
+		q := &l.quotas[i]
+		switch {
+		case q.available(n):
+		case n > q.limit:
+			return time.Time{}, false
+		default:
+			if end := q.start.Add(q.interval); end.After(at) {
+				at = end
+			}
+		}
+	}
+
+	return at, true
+}
+
+// windowAvailableAtLocked is the same as availableAtLocked for the main limit only.
+func (l *Limiter) windowAvailableAtLocked(n, reserved Limit, now time.Time) (time.Time, bool) {
+	room, ok := l.roomLocked(l.budgetLocked(), n, reserved)
+	if ok && l.usageLocked() <= room {
+		return now, true
+	}
+	if !l.warmingLocked() {
+		if !ok {
+			return time.Time{}, false
+		}
+
+		return l.freedAtLocked(room, n, reserved, now)
+	}
+
+	// The warm-up limit grows up to the full limit meanwhile.
+	if at, wok := l.warmupAvailableAtLocked(n, reserved); wok {
+		if !ok {
+			return at, true
+		}
+		if freed, fok := l.freedAtLocked(room, n, reserved, now); fok && freed.Before(at) {
+			return freed, true
+		}
+
+		return at, true
+	}
+
+	room, ok = l.roomLocked(addLimit(l.limit, l.carried), n, reserved)
+	if !ok {
+		return time.Time{}, false
+	}
+	at, ok := l.freedAtLocked(room, n, reserved, now)
+	if end := l.warmStart.Add(l.warmup); ok && end.After(at) {
+		at = end
+	}
+
+	return at, ok
+}
+
+// freedAtLocked returns the earliest time when the usage drops to the room,
+// false if it never does.
+func (l *Limiter) freedAtLocked(room, n, reserved Limit, now time.Time) (time.Time, bool) {
+	if l.usageLocked() <= room {
+		return now, true
+	}
+
+	switch {
+	case l.external:
+		// Nobody knows when AddCapacity is called.
+		return time.Time{}, false
+	case l.log != nil:
+		// The oldest events have to expire until the rest and n fit.
+		expired := int(l.current - room)
+		return l.log.entries[(l.log.head+expired-1)%len(l.log.entries)].Add(l.interval), true
+	case l.gradualRecovery:
+		at := l.stepAtLocked(addLimit(l.recovered, l.current-room))
+		return at, !at.IsZero()
+	}
+
+	end := l.windowStart.Add(l.interval)
+	if l.slidingLocked() && l.current <= room {
+		// Only the counted part of the previous window is in the way.
+		return end.Add(-l.slideLocked(room-l.current, l.previous)), true
+	}
+
+	current, carried := l.afterResetLocked(1)
+	if room, ok := l.roomLocked(addLimit(l.limit, carried), n, reserved); ok && current <= room {
+		if l.slidingLocked() {
+			return end.Add(l.interval - l.slideLocked(room-current, l.current)), true
+		}
+
+		return end, true
+	}
+
+	// Only debt is left, every next window pays it with the limit.
+	room, ok := l.roomLocked(l.limit, n, reserved)
+	if l.limit == 0 || !ok {
+		return time.Time{}, false
+	}
+	windows := (current-room-1)/l.limit + 1
+
+	return end.Add(time.Duration(windows) * l.interval), true
+}
+
+// roomLocked returns the max usage n units still fit with into the budget without the reserved units,
+// false if they never fit. With an overdraft n may go above the budget up to Limiter.maxDebt
+// while some budget is left.
+func (l *Limiter) roomLocked(budget, n, reserved Limit) (Limit, bool) {
+	if budget < reserved {
+		return 0, false
+	}
+	free := budget - reserved
+	room, ok := free-n, n <= free
+	if !l.overdraftLocked() || free == 0 || n > addLimit(free, l.maxDebt) {
+		return room, ok
+	}
+
+	overdraft := addLimit(free, l.maxDebt) - n
+	if overdraft > free-1 {
+		overdraft = free - 1
+	}
+	if !ok || overdraft > room {
+		room = overdraft
+	}
+
+	return room, true
+}
+
+// overdraftLocked reports whether the usage may go above the budget.
+// The sliding log can't keep more entries than the limit.
+func (l *Limiter) overdraftLocked() bool {
+	return l.maxDebt > 0 && l.log == nil
+}
@@ -0,0 +1,126 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// ResetGroup drives the window resets of several limiters from a single timer,
+// so all members reset at the same instant, see WithSharedSchedule.
+type ResetGroup struct {
+	mu          sync.Mutex
+	interval    time.Duration
+	windowStart time.Time
+	members     []*Limiter
+	clock       Clock
+
+	done chan struct{}
+}
+
+// GroupOption configures ResetGroup, see NewResetGroup.
+type GroupOption func(*ResetGroup)
+
+// WithGroupClock set ResetGroup.clock, the members must use the same clock, see WithClock.
+func WithGroupClock(c Clock) GroupOption {
+	return func(g *ResetGroup) {
+		g.clock = c
+	}
+}
+
+// NewResetGroup build and returns new instance ResetGroup with the interval.
+// It panics if the interval is not positive.
+func NewResetGroup(interval time.Duration, opts ...GroupOption) *ResetGroup {
+	if interval <= 0 {
+		panic("limiter: interval must be positive")
+	}
+
+	g := &ResetGroup{
+		mu:       sync.Mutex{},
+		interval: interval,
+		clock:    systemClock{},
+		done:     make(chan struct{}),
+	}
+	for i := range opts {
+		opts[i](g)
+	}
+	g.clock = steady(g.clock)
+	g.windowStart = g.clock.Now()
+
+	go g.resetAfterInterval()
+
+	return g
+}
+
+// Close group workers.
+// It must be call after the members are closed.
+func (g *ResetGroup) Close() {
+	close(g.done)
+}
+
+func (g *ResetGroup) join(l *Limiter) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	l.mu.Lock()
+	l.interval = g.interval
+	l.windowStart = g.windowStart
+	l.restartRecoveryLocked(l.windowStart)
+	l.unlock()
+
+	g.members = append(g.members, l)
+}
+
+func (g *ResetGroup) leave(l *Limiter) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for i := range g.members {
+		if g.members[i] == l {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			return
+		}
+	}
+}
+
+func (g *ResetGroup) resetAfterInterval() {
+	for {
+		g.mu.Lock()
+		next := g.windowStart.Add(g.interval)
+		g.mu.Unlock()
+
+		fire, release := after(g.clock, next.Sub(g.clock.Now()))
+		select {
+		case <-fire:
+			g.reset(g.clock.Now())
+		case <-g.done:
+			release()
+			return
+		}
+	}
+}
+
+// reset starts a new window for every member while holding all their locks.
+// The hooks of the members run after all the locks are released,
+// so a hook can use the other members and the group.
+func (g *ResetGroup) reset(now time.Time) {
+	g.mu.Lock()
+	if elapsed := now.Sub(g.windowStart); elapsed >= g.interval {
+		g.windowStart = g.windowStart.Add(elapsed / g.interval * g.interval)
+	}
+
+	for _, l := range g.members {
+		l.mu.Lock()
+	}
+	for _, l := range g.members {
+		l.advanceLocked(now)
+	}
+	var hooks []func()
+	for _, l := range g.members {
+		hooks = append(hooks, l.unlockHooks()...)
+	}
+	g.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+}
@@ -0,0 +1,112 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResetGroup(t *testing.T) {
+	clock := newFakeClock()
+	g := NewResetGroup(time.Minute, WithGroupClock(clock))
+	defer g.Close()
+
+	a := New(WithMaxLimit(10), WithClock(clock), WithSharedSchedule(g))
+	defer a.Close()
+	clock.Advance(20 * time.Second)
+	// A member joining later takes the group phase.
+	b := New(WithMaxLimit(5), WithClock(clock), WithSharedSchedule(g))
+	defer b.Close()
+
+	if !a.ResetAt().Equal(b.ResetAt()) {
+		t.Fatalf("members reset at %v and %v", a.ResetAt(), b.ResetAt())
+	}
+
+	allowed(a, 10)
+	allowed(b, 5)
+
+	// The published state isn't refreshed by the members, only by the group reset.
+	clock.Advance(40 * time.Second)
+	eventually(t, func() bool {
+		sa, sb := a.State(), b.State()
+		return sa.Used == 0 && sb.Used == 0 && sa.WindowID == 1 && sb.WindowID == 1
+	})
+}
+
+func TestResetGroupClock(t *testing.T) {
+	g := NewResetGroup(time.Minute)
+	defer g.Close()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("no panic for a member with another clock")
+		}
+	}()
+	New(WithClock(newFakeClock()), WithSharedSchedule(g))
+}
+
+func TestResetGroupLazyMemberLeaves(t *testing.T) {
+	g := NewResetGroup(time.Minute)
+	defer g.Close()
+
+	l := New(WithLazyReset(), WithSharedSchedule(g))
+	if len(g.members) != 1 {
+		t.Fatalf("group has %d members, want 1", len(g.members))
+	}
+	l.Close()
+	if len(g.members) != 0 {
+		t.Fatalf("group has %d members after Close, want 0", len(g.members))
+	}
+}
+
+func TestKeyedEvictionLeavesResetGroup(t *testing.T) {
+	g := NewResetGroup(time.Minute)
+	defer g.Close()
+
+	k := NewKeyed(WithKeyDefaults(WithSharedSchedule(g)), WithIdleTimeout(time.Hour))
+	defer k.Close()
+
+	k.Get("a")
+	k.Get("b")
+	if len(g.members) != 2 {
+		t.Fatalf("group has %d members, want 2", len(g.members))
+	}
+
+	// Unused keys with no usage are evicted once they are idle.
+	for i := range k.shards {
+		for _, e := range k.shards[i].keys {
+			e.lastUsed -= int64(2 * time.Hour)
+		}
+	}
+	k.evict()
+	if k.Len() != 0 || len(g.members) != 0 {
+		t.Fatalf("%d keys and %d group members after eviction, want none", k.Len(), len(g.members))
+	}
+}
+
+func TestResetGroupHookUsesMember(t *testing.T) {
+	clock := newFakeClock()
+	g := NewResetGroup(time.Minute, WithGroupClock(clock))
+	defer g.Close()
+
+	var b *Limiter
+	allowedB := make(chan bool, 1)
+	a := New(WithMaxLimit(1), WithClock(clock), WithSharedSchedule(g), WithOnReset(func() {
+		allowedB <- b.Allow()
+	}))
+	defer a.Close()
+	b = New(WithMaxLimit(1), WithClock(clock), WithSharedSchedule(g))
+	defer b.Close()
+
+	a.Allow()
+	b.Allow()
+	clock.Advance(time.Minute)
+
+	select {
+	case ok := <-allowedB:
+		if !ok {
+			t.Fatal("the other member isn't reset when the hook runs")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the hook is blocked on the other member")
+	}
+}
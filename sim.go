@@ -0,0 +1,96 @@
+package limiter
+
+import (
+	"sort"
+	"time"
+)
+
+// Report is the result of Simulate.
+type Report struct {
+	Allowed uint64
+	Denied  uint64
+	// MaxDelay is the longest time a denied event would have waited for a unit,
+	// Never if a unit could never be available.
+	MaxDelay time.Duration
+	// Windows are the windows with at least one event from the oldest to the newest,
+	// idle windows between them are skipped.
+	Windows []WindowRecord
+}
+
+// Simulate replays events through a limiter built with opts and reports its decisions.
+// Use it to review a configuration against recorded traffic. The events are sorted first,
+// the limiter uses lazy reset starting at the first event and a clock which stays
+// at the replayed event instead of the wall clock, so the result is deterministic. The deny policy and filters aren't applied
+// and a shared schedule must not be used.
+func Simulate(opts []Option, events []time.Time) Report {
+	if len(events) == 0 {
+		return Report{}
+	}
+
+	sorted := append([]time.Time(nil), events...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+
+	clock := &simClock{now: sorted[0]}
+	opts = append(append([]Option(nil), opts...), WithLazyReset(), WithStartTime(sorted[0]), WithClock(clock))
+	l := New(opts...)
+
+	var (
+		report Report
+		denied uint64
+	)
+	// record adds the current window, it is called before the window is reset,
+	// so idle windows are never recorded.
+	record := func() {
+		report.Windows = append(report.Windows, WindowRecord{
+			Start:  l.windowStart,
+			End:    l.windowStart.Add(l.interval),
+			Used:   l.current,
+			Limit:  l.budgetLocked(),
+			Denied: denied,
+		})
+		denied = 0
+	}
+
+	for _, t := range sorted {
+		clock.now = t
+		l.mu.Lock()
+		if !l.external && !t.Before(l.windowStart.Add(l.interval)) {
+			record()
+		}
+		if l.allowLocked(1, l.reserved, false, t) {
+			report.Allowed++
+		} else {
+			report.Denied++
+			denied++
+			delay := Never
+			if at, ok := l.availableAtLocked(1, l.reserved, t); ok {
+				delay = at.Sub(t)
+			}
+			if delay > report.MaxDelay {
+				report.MaxDelay = delay
+			}
+		}
+		l.unlock()
+	}
+
+	if !l.external {
+		l.mu.Lock()
+		record()
+		l.unlock()
+	}
+
+	return report
+}
+
+// simClock is the clock of Simulate, it never fires the timers of a lazy limiter.
+type simClock struct {
+	now time.Time
+}
+
+func (c *simClock) Now() time.Time {
+	return c.now
+}
+
+func (c *simClock) After(time.Duration) <-chan time.Time {
+	return nil
+}
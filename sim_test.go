@@ -0,0 +1,69 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimulate(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []time.Time{
+		t0.Add(10 * time.Minute),
+		t0.Add(20 * time.Second),
+		t0,
+		t0.Add(10 * time.Second),
+	}
+	opts := []Option{WithRate(2, time.Minute)}
+
+	got := Simulate(opts, events)
+	want := Report{
+		Allowed:  3,
+		Denied:   1,
+		MaxDelay: 40 * time.Second,
+		// The idle windows between the events are skipped.
+		Windows: []WindowRecord{
+			{Start: t0, End: t0.Add(time.Minute), Used: 2, Limit: 2, Denied: 1},
+			{Start: t0.Add(10 * time.Minute), End: t0.Add(11 * time.Minute), Used: 1, Limit: 2},
+		},
+	}
+	if !sameReport(got, want) {
+		t.Fatalf("report %+v, want %+v", got, want)
+	}
+
+	// The order of the events doesn't matter and the input isn't changed.
+	reversed := []time.Time{events[0], events[3], events[1], events[2]}
+	if again := Simulate(opts, reversed); !sameReport(again, got) {
+		t.Fatalf("report of the reordered events %+v, want %+v", again, got)
+	}
+	if !events[0].Equal(t0.Add(10 * time.Minute)) {
+		t.Fatal("Simulate sorted the input")
+	}
+}
+
+func TestSimulateIdleGap(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []time.Time{t0, t0.AddDate(1000, 0, 0)}
+
+	got := Simulate([]Option{WithRate(1, time.Second)}, events)
+	if got.Allowed != 2 || got.Denied != 0 {
+		t.Fatalf("allowed %d, denied %d, want 2 and 0", got.Allowed, got.Denied)
+	}
+	if len(got.Windows) != 2 {
+		t.Fatalf("%d windows, want 2", len(got.Windows))
+	}
+}
+
+// sameReport compares the reports with the window times compared as instants.
+func sameReport(a, b Report) bool {
+	if a.Allowed != b.Allowed || a.Denied != b.Denied || a.MaxDelay != b.MaxDelay || len(a.Windows) != len(b.Windows) {
+		return false
+	}
+	for i, w := range a.Windows {
+		v := b.Windows[i]
+		if !w.Start.Equal(v.Start) || !w.End.Equal(v.End) || w.Used != v.Used || w.Limit != v.Limit || w.Denied != v.Denied {
+			return false
+		}
+	}
+
+	return true
+}
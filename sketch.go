@@ -0,0 +1,161 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithApproximateKeys set Keyed.sketch, the keys are counted in a count-min sketch of depth
+// rows of width counters instead of a limiter per key, so the memory doesn't grow with the keys.
+// The limit, the interval and the clock come from the key defaults, the other options,
+// the key options and the key configs are not used, Get returns nil and Len zero.
+// A key may be over-counted by the keys it collides with, but never under-counted:
+// it is denied early with the probability and the error of ApproximateError, never late.
+// The sketch is double-buffered, a window reset swaps the buffers and the old one is
+// cleared in the background. It panics if width or depth is not positive.
+func WithApproximateKeys(width, depth int) KeyedOption {
+	if width <= 0 || depth <= 0 {
+		panic("limiter: WithApproximateKeys width and depth must be positive")
+	}
+
+	return func(k *Keyed) {
+		k.sketch = &sketch{width: width, depth: depth}
+	}
+}
+
+// ApproximateError returns the error bounds of WithApproximateKeys: with the probability
+// of at least 1-delta a key is over-counted by at most epsilon times the count of all the keys
+// in the window. It returns zeros if the keys are exact.
+func (k *Keyed) ApproximateError() (epsilon, delta float64) {
+	if k.sketch == nil {
+		return 0, 0
+	}
+
+	return math.E / float64(k.sketch.width), math.Exp(-float64(k.sketch.depth))
+}
+
+// sketch is a count-min sketch of the keys used in the current window.
+type sketch struct {
+	width, depth int
+	limit        Limit
+	interval     time.Duration
+	clock        Clock
+
+	// mu is held for reading by the calls and for writing by the window reset.
+	mu      sync.RWMutex
+	start   time.Time
+	counts  []uint64
+	spare   []uint64
+	cleared chan struct{}
+}
+
+// init takes the limit, the interval and the clock from the defaults.
+func (s *sketch) init(defaults []Option) {
+	l := Limiter{limit: Infinite, interval: defaultInterval, clock: systemClock{}}
+	for _, opt := range defaults {
+		opt(&l)
+	}
+	if l.interval <= 0 {
+		panic("limiter: interval must be positive")
+	}
+
+	s.limit, s.interval, s.clock = l.limit, l.interval, steady(l.clock)
+	s.start = s.clock.Now()
+	s.counts = make([]uint64, s.width*s.depth)
+	s.spare = make([]uint64, s.width*s.depth)
+	s.cleared = make(chan struct{})
+	close(s.cleared)
+}
+
+// allow adds n to the count of the key and undoes it if the estimate is above the limit.
+func (s *sketch) allow(key string, n Limit) bool {
+	if s.limit == Infinite {
+		return true
+	}
+
+	s.mu.RLock()
+	if now := s.clock.Now(); now.Sub(s.start) >= s.interval {
+		s.mu.RUnlock()
+		s.reset(now)
+		s.mu.RLock()
+	}
+	defer s.mu.RUnlock()
+
+	estimate := uint64(math.MaxUint64)
+	h1, h2 := sketchHash(key)
+	for i := 0; i < s.depth; i++ {
+		if c := atomic.AddUint64(&s.counts[s.cell(i, h1, h2)], uint64(n)); c < estimate {
+			estimate = c
+		}
+	}
+	if estimate <= uint64(s.limit) {
+		return true
+	}
+
+	for i := 0; i < s.depth; i++ {
+		atomic.AddUint64(&s.counts[s.cell(i, h1, h2)], ^uint64(n-1))
+	}
+
+	return false
+}
+
+// reset starts the window now is in with the cleared spare buffer.
+func (s *sketch) reset(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := now.Sub(s.start)
+	if elapsed < s.interval {
+		return
+	}
+	s.start = s.start.Add(elapsed / s.interval * s.interval)
+
+	<-s.cleared
+	s.counts, s.spare = s.spare, s.counts
+	cleared := make(chan struct{})
+	s.cleared = cleared
+	go func(counts []uint64) {
+		for i := range counts {
+			counts[i] = 0
+		}
+		close(cleared)
+	}(s.spare)
+}
+
+// wait waits for the end of the window, false if ctx is done first.
+func (s *sketch) wait(ctx context.Context, done <-chan struct{}) bool {
+	s.mu.RLock()
+	end := s.start.Add(s.interval)
+	s.mu.RUnlock()
+
+	fire, release := after(s.clock, end.Sub(s.clock.Now()))
+	defer release()
+
+	select {
+	case <-fire:
+		return true
+	case <-ctx.Done():
+	case <-done:
+	}
+
+	return false
+}
+
+// cell returns the index of the counter of row i, the rows use the hashes h1+i*h2.
+func (s *sketch) cell(i int, h1, h2 uint32) int {
+	return i*s.width + int((h1+uint32(i)*h2)%uint32(s.width))
+}
+
+// sketchHash returns two hashes of the key, the halves of its 64-bit FNV-1a hash.
+func sketchHash(key string) (uint32, uint32) {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+
+	return uint32(h), uint32(h>>32) | 1
+}
@@ -0,0 +1,96 @@
+package limiter
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestApproximateKeys(t *testing.T) {
+	const (
+		limit = 10
+		width = 8192
+		depth = 4
+		cold  = 20000
+	)
+
+	clock := newFakeClock()
+	k := NewKeyed(WithKeyDefaults(WithRate(limit, time.Minute), WithClock(clock)), WithApproximateKeys(width, depth))
+	defer k.Close()
+
+	// The hot key comes first, so it is counted exactly.
+	if hot := allowedKey(k, "hot", 100); hot != limit {
+		t.Fatalf("allowed %d of the hot key, want %d", hot, limit)
+	}
+
+	// Every cold key is used once, it is denied only if the estimate is over by the whole limit.
+	random := rand.New(rand.NewSource(1))
+	denied := 0
+	for i := 0; i < cold; i++ {
+		if !k.Allow(strconv.FormatUint(random.Uint64(), 36)) {
+			denied++
+		}
+	}
+	epsilon, delta := k.ApproximateError()
+	if over := epsilon * (cold + limit); over >= limit {
+		t.Fatalf("the error bound %.1f isn't below the limit, the workload doesn't test it", over)
+	}
+	if rate := float64(denied) / cold; rate > delta {
+		t.Fatalf("denied %.4f of the cold keys, want at most %.4f", rate, delta)
+	}
+	if k.Allow("hot") {
+		t.Fatal("allowed the hot key among the cold ones")
+	}
+
+	// The memory doesn't grow with the keys.
+	if n := k.Len(); n != 0 || k.Get("hot") != nil {
+		t.Fatalf("%d key limiters in the approximate mode, want none", n)
+	}
+	if len(k.sketch.counts) != width*depth || len(k.sketch.spare) != width*depth {
+		t.Fatalf("%d and %d counters, want %d", len(k.sketch.counts), len(k.sketch.spare), width*depth)
+	}
+
+	// The next windows start from zero counts.
+	for w := 0; w < 3; w++ {
+		clock.Advance(time.Minute)
+		if got := allowedKey(k, "hot", 100); got != limit {
+			t.Fatalf("allowed %d of the hot key in window %d, want %d", got, w+1, limit)
+		}
+	}
+}
+
+func TestApproximateKeysWait(t *testing.T) {
+	clock := newFakeClock()
+	k := NewKeyed(WithKeyDefaults(WithRate(1, time.Minute), WithClock(clock)), WithApproximateKeys(64, 2))
+
+	k.Allow("a")
+	done := make(chan bool)
+	go func() { done <- k.Wait(context.Background(), "a") }()
+	eventually(t, func() bool {
+		_, pending := clock.wakeups()
+		return pending > 0
+	})
+	clock.Advance(time.Minute)
+	if !<-done {
+		t.Fatal("Wait failed in the next window")
+	}
+
+	k.Close()
+	if k.Allow("b") || k.Wait(context.Background(), "b") {
+		t.Fatal("allowed after Close")
+	}
+}
+
+// allowedKey calls Allow of the key n times and returns how many were allowed.
+func allowedKey(k *Keyed, key string, n int) int {
+	got := 0
+	for i := 0; i < n; i++ {
+		if k.Allow(key) {
+			got++
+		}
+	}
+
+	return got
+}
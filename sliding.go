@@ -0,0 +1,45 @@
+package limiter
+
+import (
+	"math"
+	"time"
+)
+
+// slidingLocked reports whether the previous window is counted, see WithSlidingWindow.
+func (l *Limiter) slidingLocked() bool {
+	return l.slidingWindow && l.log == nil && !l.gradualRecovery && !l.external
+}
+
+// previousLocked returns the part of the previous window usage which is still counted at now.
+// The previous window is weighted by the part of it overlapping the trailing interval,
+// rounded up, so the estimate doesn't let more than limit units through per interval.
+func (l *Limiter) previousLocked(now time.Time) Limit {
+	if !l.slidingLocked() || l.previous == 0 {
+		return 0
+	}
+
+	left := l.windowStart.Add(l.interval).Sub(now)
+	switch {
+	case left <= 0:
+		return 0
+	case left > l.interval:
+		left = l.interval
+	}
+
+	return Limit(math.Ceil(float64(l.previous) * float64(left) / float64(l.interval)))
+}
+
+// usageLocked returns the usage including the counted part of the previous window.
+func (l *Limiter) usageLocked() Limit {
+	return addLimit(l.current, l.previousLocked(l.lastNow))
+}
+
+// slideLocked returns how long before the window end the counted part of previous
+// drops to free units.
+func (l *Limiter) slideLocked(free, previous Limit) time.Duration {
+	if free >= previous {
+		return l.interval
+	}
+
+	return time.Duration(float64(l.interval) * float64(free) / float64(previous))
+}
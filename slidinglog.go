@@ -0,0 +1,66 @@
+package limiter
+
+import "time"
+
+// slidingLog is a ring of timestamps of admitted events.
+type slidingLog struct {
+	entries []time.Time
+	head    int
+	size    int
+}
+
+func newSlidingLog(capacity int) *slidingLog {
+	return &slidingLog{entries: make([]time.Time, capacity)}
+}
+
+func (s *slidingLog) push(t time.Time) {
+	s.entries[(s.head+s.size)%len(s.entries)] = t
+	s.size++
+}
+
+// oldest returns the timestamp of the oldest event.
+func (s *slidingLog) oldest() (time.Time, bool) {
+	if s.size == 0 {
+		return time.Time{}, false
+	}
+
+	return s.entries[s.head], true
+}
+
+// prune removes events which happened not after t.
+func (s *slidingLog) prune(t time.Time) {
+	for s.size > 0 && !s.entries[s.head].After(t) {
+		s.entries[s.head] = time.Time{}
+		s.head = (s.head + 1) % len(s.entries)
+		s.size--
+	}
+}
+
+// dropNewest removes the latest n events.
+func (s *slidingLog) dropNewest(n int) {
+	if n > s.size {
+		n = s.size
+	}
+
+	s.size -= n
+}
+
+// resize changes the capacity, dropping the oldest events which don't fit.
+func (s *slidingLog) resize(capacity int) {
+	if capacity == len(s.entries) {
+		return
+	}
+
+	entries := make([]time.Time, capacity)
+	skip := 0
+	if s.size > capacity {
+		skip = s.size - capacity
+	}
+	for i := skip; i < s.size; i++ {
+		entries[i-skip] = s.entries[(s.head+i)%len(s.entries)]
+	}
+
+	s.entries = entries
+	s.head = 0
+	s.size -= skip
+}
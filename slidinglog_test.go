@@ -0,0 +1,54 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithSlidingLogNoBoundaryBurst(t *testing.T) {
+	clock := newFakeClock()
+	fixed := New(WithRate(10, 10*time.Second), WithClock(clock))
+	defer fixed.Close()
+	sliding := New(WithRate(10, 10*time.Second), WithClock(clock), WithSlidingLog(10))
+	defer sliding.Close()
+
+	clock.Advance(9 * time.Second)
+	allowed(fixed, 10)
+	allowed(sliding, 10)
+
+	// The fixed window lets another full limit through a second later.
+	clock.Advance(time.Second)
+	if got := allowed(fixed, 10); got != 10 {
+		t.Fatalf("fixed window allowed %d at the boundary, want 10", got)
+	}
+	if got := allowed(sliding, 10); got != 0 {
+		t.Fatalf("sliding log allowed %d at the boundary, want 0", got)
+	}
+
+	clock.Advance(9*time.Second - time.Nanosecond)
+	if sliding.Allow() {
+		t.Fatal("sliding log allowed before the events left the trailing interval")
+	}
+	clock.Advance(time.Nanosecond)
+	if got := allowed(sliding, 11); got != 10 {
+		t.Fatalf("sliding log allowed %d after the events expired, want 10", got)
+	}
+}
+
+func TestWithSlidingLogExpiresOldest(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(10, 10*time.Second), WithClock(clock), WithSlidingLog(10))
+	defer l.Close()
+
+	allowed(l, 4)
+	clock.Advance(5 * time.Second)
+	allowed(l, 6)
+
+	clock.Advance(5 * time.Second)
+	if got := allowed(l, 10); got != 4 {
+		t.Fatalf("allowed %d, want the 4 expired events", got)
+	}
+	if got := l.RetryAfter(); got != 5*time.Second {
+		t.Fatalf("retry after %v, want the expiry of the next oldest event", got)
+	}
+}
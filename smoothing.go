@@ -0,0 +1,45 @@
+package limiter
+
+import (
+	"math"
+	"time"
+)
+
+// spacedLocked reports whether the gap after the previous grant has passed, see WithSmoothing.
+func (l *Limiter) spacedLocked() bool {
+	return !l.smoothing || !l.lastNow.Before(l.nextGrant)
+}
+
+// spaceLocked schedules the next grant after n units were taken at now.
+// A grant on time keeps the cadence, so a late wake-up doesn't shift all the following ones.
+func (l *Limiter) spaceLocked(n Limit, now time.Time) {
+	if !l.smoothing {
+		return
+	}
+
+	gap := l.spacingLocked()
+	if gap == 0 {
+		return
+	}
+
+	base := now
+	if late := now.Sub(l.nextGrant); !l.nextGrant.IsZero() && late < gap {
+		base = l.nextGrant
+	}
+
+	delay := time.Duration(math.MaxInt64)
+	if n <= Limit(math.MaxInt64/gap) {
+		delay = gap * time.Duration(n)
+	}
+	l.nextGrant = base.Add(delay)
+	l.wakeCleanup()
+}
+
+// smoothingEventLocked returns when the next grant is allowed, the zero time if it is allowed now.
+func (l *Limiter) smoothingEventLocked() time.Time {
+	if l.spacedLocked() {
+		return time.Time{}
+	}
+
+	return l.nextGrant
+}
@@ -0,0 +1,114 @@
+package limiter
+
+import (
+	"fmt"
+	"time"
+)
+
+// State is a consistent snapshot of the limiter, see Limiter.State.
+type State struct {
+	// Limit is the budget of the current window, the limit plus carried units.
+	Limit Limit
+	Used  Limit
+	// Remaining is Limit minus Used, or zero if the usage is above the limit.
+	// It includes the reserved part, see WithReserved, and is lowered by a tighter quota, see WithQuota.
+	Remaining Limit
+	// WindowStart and NextReset are the bounds of the current window.
+	// In sliding log mode they are the oldest admitted event and its expiry.
+	WindowStart time.Time
+	NextReset   time.Time
+	// WindowID is the number of the current window, see Limiter.WindowID.
+	WindowID        uint64
+	GradualRecovery bool
+	// Waiters is the number of goroutines blocked in Wait.
+	Waiters int
+}
+
+// String implements fmt.Stringer.
+func (s State) String() string {
+	limit := "inf"
+	if s.Limit != Infinite {
+		limit = fmt.Sprint(uint64(s.Limit))
+	}
+
+	return fmt.Sprintf("used %d of %s, next reset at %s", s.Used, limit, s.NextReset.Format(time.RFC3339))
+}
+
+// State returns a consistent snapshot of the limiter.
+// It doesn't take the lock unless a reset is due.
+func (l *Limiter) State() State {
+	l.checkNew()
+	if state, _, ok := l.loadObserved(l.now()); ok {
+		return state
+	}
+
+	now := l.lock()
+	defer l.unlock()
+
+	return l.stateLocked(now)
+}
+
+// String implements fmt.Stringer.
+func (l *Limiter) String() string {
+	state := l.State()
+	if l.name == "" {
+		return "limiter: " + state.String()
+	}
+
+	return fmt.Sprintf("limiter %q: %s", l.name, state)
+}
+
+func (l *Limiter) stateLocked(now time.Time) State {
+	state := State{
+		Limit:           l.budgetLocked(),
+		Used:            l.current,
+		WindowStart:     l.windowStart,
+		NextReset:       l.windowStart.Add(l.interval),
+		GradualRecovery: l.gradualRecovery,
+		Waiters:         l.waiters.len(),
+		WindowID:        l.windowID,
+		Remaining:       l.remainingLocked(),
+	}
+
+	if l.log != nil {
+		state.GradualRecovery = false
+		state.WindowStart = now
+		if oldest, ok := l.log.oldest(); ok {
+			state.WindowStart = oldest
+		}
+		state.NextReset = state.WindowStart.Add(l.interval)
+	}
+
+	return state
+}
+
+// windowRemainingLocked returns the budget minus the usage, zero if the usage is above it
+// after the limit is lowered or in debt. In sliding window mode the counted part
+// of the previous window is a part of the usage.
+func (l *Limiter) windowRemainingLocked() Limit {
+	budget := l.budgetLocked()
+	if budget == Infinite && !l.external {
+		return Infinite
+	}
+
+	used := l.usageLocked()
+	if used >= budget {
+		return 0
+	}
+
+	return budget - used
+}
+
+// remainingLocked returns the remaining units of the tightest of the window
+// and the quotas, see WithQuota.
+func (l *Limiter) remainingLocked() Limit {
+	remaining := l.windowRemainingLocked()
+	for i := range l.quotas {
+		q := &l.quotas[i]
+		if left := q.remaining(); left < remaining {
+			remaining = left
+		}
+	}
+
+	return remaining
+}
@@ -0,0 +1,58 @@
+package limiter
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStateConsistent(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(100, time.Second), WithClock(clock))
+	defer l.Close()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					l.Allow()
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				clock.Advance(100 * time.Millisecond)
+				time.Sleep(time.Microsecond)
+			}
+		}
+	}()
+
+	for i := 0; i < 5000; i++ {
+		s := l.State()
+		if s.Limit != 100 || s.Used+s.Remaining != s.Limit {
+			t.Errorf("used %d and remaining %d of %d", s.Used, s.Remaining, s.Limit)
+			break
+		}
+		if !s.NextReset.After(s.WindowStart) {
+			t.Errorf("next reset %v isn't after the window start %v", s.NextReset, s.WindowStart)
+			break
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,144 @@
+package limiter
+
+import "time"
+
+// WaitBuckets are the upper bounds of Stats.WaitDurations.
+var WaitBuckets = [...]time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// Stats contains counters of the limiter decisions.
+type Stats struct {
+	// Allowed is the number of consumed units.
+	Allowed uint64
+	// Denied is the number of calls which exceeded the limit,
+	// including the calls let through by shadow mode.
+	Denied uint64
+	// Shed is the number of calls rejected early by the Shed policy below the limit,
+	// they aren't counted in Denied.
+	Shed uint64
+	// Filtered is the number of calls denied by filters, see WithFilter.
+	Filtered uint64
+	// Blocked is the number of calls denied while the key was blocked, see Keyed.Block.
+	// They aren't counted in Filtered.
+	Blocked uint64
+	// WouldHaveDenied is the number of denied calls let through by shadow mode.
+	WouldHaveDenied uint64
+	// Granted is the number of units added by AddCapacity, see WithExternalRefill.
+	Granted uint64
+	// CaughtUp is the usage restored by New for the time passed since WithStartTime.
+	CaughtUp Limit
+	// Carried is the number of units carried over from the previous windows, see WithCarryOver.
+	Carried Limit
+	// Waited is the number of waits which blocked before they were granted.
+	Waited uint64
+	// WaitDurations is the histogram of the blocked waits, the count at i is the number
+	// of waits not longer than WaitBuckets[i], the last one counts the longer waits.
+	WaitDurations [len(WaitBuckets) + 1]uint64
+	// Waiters is the number of goroutines blocked in Wait.
+	Waiters int
+	// Rate is the observed number of consumed units per second, see Limiter.Rate.
+	Rate float64
+}
+
+// DenyInfo describes a call which exceeded the limit.
+type DenyInfo struct {
+	Used  Limit
+	Limit Limit
+	// Shadow is true if the call was let through by shadow mode.
+	Shadow bool
+}
+
+// EventKind is the kind of Event.
+type EventKind uint8
+
+const (
+	// EventAllowed is sent when units are consumed.
+	EventAllowed EventKind = iota
+	// EventDenied is sent when a call exceeded the limit.
+	EventDenied
+	// EventWaited is sent when a blocked wait was granted.
+	EventWaited
+)
+
+// Event describes a decision of the limiter, see WithOnEvent.
+type Event struct {
+	Kind EventKind
+	// N is the number of units of the call.
+	N Limit
+	// Used and Limit are the usage and the budget after the decision.
+	Used  Limit
+	Limit Limit
+	// Wait is how long an EventWaited waiter was blocked.
+	Wait time.Duration
+}
+
+// Stats returns counters of the limiter decisions.
+// It doesn't take the lock unless a reset is due.
+func (l *Limiter) Stats() Stats {
+	l.checkNew()
+	_, stats, ok := l.loadObserved(l.now())
+	if !ok {
+		l.lock()
+		stats = l.stats
+		stats.Carried = l.carried
+		stats.Waiters = l.waiters.len()
+		l.unlock()
+	}
+
+	stats.Rate = l.Rate()
+
+	return stats
+}
+
+// SetShadowMode switches shadow mode on a running limiter, see WithShadowMode.
+func (l *Limiter) SetShadowMode(enabled bool) {
+	l.checkNew()
+	l.mu.Lock()
+	l.shadow = enabled
+	l.unlock()
+}
+
+// denyLocked records a call for n units which exceeded the limit and returns the decision for it.
+func (l *Limiter) denyLocked(n Limit) bool {
+	l.stats.Denied++
+	if l.shadow {
+		l.stats.WouldHaveDenied++
+	}
+
+	if l.onDeny != nil {
+		fn, info := l.onDeny, DenyInfo{Used: l.current, Limit: l.budgetLocked(), Shadow: l.shadow}
+		l.hooks = append(l.hooks, func() { fn(info) })
+	}
+	l.eventLocked(EventDenied, n, 0)
+
+	return l.shadow
+}
+
+// waited records a wait for n units which was blocked for d.
+func (l *Limiter) waited(n Limit, d time.Duration) {
+	owner := l.lockOwner()
+	defer owner.unlock()
+
+	owner.stats.Waited++
+	bucket := 0
+	for bucket < len(WaitBuckets) && d > WaitBuckets[bucket] {
+		bucket++
+	}
+	owner.stats.WaitDurations[bucket]++
+	owner.eventLocked(EventWaited, n, d)
+}
+
+// eventLocked sends the event to the hook after the lock is released.
+func (l *Limiter) eventLocked(kind EventKind, n Limit, wait time.Duration) {
+	if l.onEvent == nil {
+		return
+	}
+
+	fn, event := l.onEvent, Event{Kind: kind, N: n, Used: l.current, Limit: l.budgetLocked(), Wait: wait}
+	l.hooks = append(l.hooks, func() { fn(event) })
+}
@@ -0,0 +1,97 @@
+package limiter
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithShadowModeCounters(t *testing.T) {
+	clock := newFakeClock()
+	enforcing := New(WithRate(5, time.Minute), WithClock(clock))
+	defer enforcing.Close()
+
+	var shadowed int
+	shadow := New(WithRate(5, time.Minute), WithClock(clock), WithShadowMode(), WithOnDeny(func(info DenyInfo) {
+		if !info.Shadow {
+			t.Error("OnDeny of shadow mode isn't marked as shadow")
+		}
+		shadowed++
+	}))
+	defer shadow.Close()
+
+	for _, step := range []struct {
+		calls   int
+		advance time.Duration
+	}{{3, 10 * time.Second}, {4, 10 * time.Second}, {2, time.Minute}, {7, 0}} {
+		for i := 0; i < step.calls; i++ {
+			enforcing.Allow()
+			if !shadow.Allow() {
+				t.Fatal("shadow mode denied a call")
+			}
+		}
+		if e, s := enforcing.State(), shadow.State(); e != s {
+			t.Fatalf("shadow state %+v, enforcing %+v", s, e)
+		}
+		clock.Advance(step.advance)
+	}
+
+	e, s := enforcing.Stats(), shadow.Stats()
+	if e.Allowed != s.Allowed || e.Denied != s.Denied {
+		t.Fatalf("shadow stats %+v, enforcing %+v", s, e)
+	}
+	if e.Denied != 6 || s.WouldHaveDenied != 6 || e.WouldHaveDenied != 0 || shadowed != 6 {
+		t.Fatalf("denied %d, would have denied %d, OnDeny %d, want 6", e.Denied, s.WouldHaveDenied, shadowed)
+	}
+
+	// Wait doesn't block either.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if !shadow.Wait(ctx) {
+		t.Fatal("shadow mode blocked Wait")
+	}
+}
+
+func TestWithOnEvent(t *testing.T) {
+	clock := newFakeClock()
+	var (
+		mu     sync.Mutex
+		events []Event
+	)
+	l := New(WithRate(2, time.Minute), WithClock(clock), WithOnEvent(func(e Event) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	}))
+	defer l.Close()
+
+	allowed(l, 3)
+	done := make(chan bool)
+	go func() { done <- l.Wait(context.Background()) }()
+	eventually(t, func() bool { return l.Waiters() == 1 })
+	clock.Advance(time.Minute)
+	<-done
+	l.AllowN(5)
+
+	// The blocked wait sends the consumption and then how long it waited.
+	want := []Event{
+		{Kind: EventAllowed, N: 1, Used: 1, Limit: 2},
+		{Kind: EventAllowed, N: 1, Used: 2, Limit: 2},
+		{Kind: EventDenied, N: 1, Used: 2, Limit: 2},
+		{Kind: EventAllowed, N: 1, Used: 1, Limit: 2},
+		{Kind: EventWaited, N: 1, Used: 1, Limit: 2, Wait: time.Minute},
+		{Kind: EventDenied, N: 5, Used: 1, Limit: 2},
+	}
+	eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events) == len(want)
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events %+v, want %+v", events, want)
+	}
+}
@@ -0,0 +1,35 @@
+package limiter
+
+import "context"
+
+// checkStrictLocked panics in strict mode if the limiter is used after Close.
+// The lock is released before the panic.
+func (l *Limiter) checkStrictLocked(method string) {
+	if l.strict && l.closed {
+		l.mu.Unlock()
+		panic("limiter: " + method + " called after Close")
+	}
+}
+
+// checkStrictWait panics in strict mode if Wait is called after Close or with a done context.
+func (l *Limiter) checkStrictWait(ctx context.Context, method string) {
+	if !l.strict {
+		return
+	}
+
+	if ctx.Err() != nil {
+		panic("limiter: " + method + " called with a done context")
+	}
+
+	l.mu.Lock()
+	l.checkStrictLocked(method)
+	l.mu.Unlock()
+}
+
+// isClosed reports whether Close was called.
+func (l *Limiter) isClosed() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.closed
+}
@@ -0,0 +1,81 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithStrict(t *testing.T) {
+	done, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		closed bool
+		call   func(l *Limiter)
+		panic  string
+	}{
+		{"Allow", true, func(l *Limiter) { l.Allow() }, "limiter: Allow called after Close"},
+		{"AllowN", true, func(l *Limiter) { l.AllowN(2) }, "limiter: AllowN called after Close"},
+		{"AllowAt", true, func(l *Limiter) { l.AllowAt(l.now()) }, "limiter: AllowAt called after Close"},
+		{"AllowReserved", true, func(l *Limiter) { l.AllowReserved() }, "limiter: AllowReserved called after Close"},
+		{"SetLimit", true, func(l *Limiter) { l.SetLimit(5) }, "limiter: SetLimit called after Close"},
+		{"SetInterval", true, func(l *Limiter) { l.SetInterval(time.Second) }, "limiter: SetInterval called after Close"},
+		{"TryAcquire", true, func(l *Limiter) { l.TryAcquire() }, "limiter: TryAcquire called after Close"},
+		{"Wait", true, func(l *Limiter) { l.Wait(context.Background()) }, "limiter: Wait called after Close"},
+		{"WaitN", true, func(l *Limiter) { l.WaitN(context.Background(), 1) }, "limiter: WaitN called after Close"},
+		{"Wait done", false, func(l *Limiter) { l.Wait(done) }, "limiter: Wait called with a done context"},
+		{"WaitErr done", false, func(l *Limiter) { l.WaitErr(done) }, "limiter: WaitErr called with a done context"},
+		{"WaitDuration done", false, func(l *Limiter) { l.WaitDuration(done) }, "limiter: WaitDuration called with a done context"},
+		{"Token.Commit", false, func(l *Limiter) {
+			token, _ := l.TryAcquire()
+			token.Commit()
+			token.Commit()
+		}, "limiter: Token.Commit called on a resolved token"},
+		{"Token.Rollback", false, func(l *Limiter) {
+			token, _ := l.TryAcquire()
+			token.Rollback()
+			token.Rollback()
+		}, "limiter: Token.Rollback called on a resolved token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, strict := range []bool{false, true} {
+				opts := []Option{WithMaxLimit(10)}
+				if strict {
+					opts = append(opts, WithStrict())
+				}
+				l := New(opts...)
+				if tt.closed {
+					l.Close()
+				}
+
+				got := func() (msg interface{}) {
+					defer func() { msg = recover() }()
+					tt.call(l)
+					return nil
+				}()
+
+				switch {
+				case !strict && got != nil:
+					t.Errorf("lenient mode panicked with %v", got)
+				case strict && got != tt.panic:
+					t.Errorf("strict mode panicked with %v, want %q", got, tt.panic)
+				}
+				l.Close()
+			}
+		})
+	}
+}
+
+func TestWithStrictAllowErr(t *testing.T) {
+	l := New(WithStrict())
+	l.Close()
+
+	if err := l.AllowErr(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("AllowErr after Close returned %v, want ErrClosed", err)
+	}
+}
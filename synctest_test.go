@@ -0,0 +1,55 @@
+//go:build go1.25
+
+package limiter
+
+import (
+	"context"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+func TestSynctestWindowReset(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		l := New(WithRate(2, time.Hour))
+		defer l.Close()
+
+		if got := allowed(l, 3); got != 2 {
+			t.Fatalf("allowed %d, want 2", got)
+		}
+
+		time.Sleep(time.Hour)
+		if got := allowed(l, 3); got != 2 {
+			t.Fatalf("allowed %d after the window reset, want 2", got)
+		}
+		if got := l.WindowID(); got != 1 {
+			t.Fatalf("window %d, want 1", got)
+		}
+	})
+}
+
+func TestSynctestBlockedWait(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		l := New(WithRate(1, time.Hour))
+		defer l.Close()
+
+		l.Allow()
+		start := time.Now()
+
+		done := make(chan bool)
+		go func() { done <- l.Wait(context.Background()) }()
+
+		// The waiter and the cleanup goroutine are durably blocked until the reset.
+		synctest.Wait()
+		if n := l.Waiters(); n != 1 {
+			t.Fatalf("%d waiters, want 1", n)
+		}
+
+		if !<-done {
+			t.Fatal("Wait failed")
+		}
+		if waited := time.Since(start); waited != time.Hour {
+			t.Fatalf("waited %v, want an hour", waited)
+		}
+	})
+}
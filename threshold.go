@@ -0,0 +1,37 @@
+package limiter
+
+type threshold struct {
+	fraction float64
+	fn       func(used, limit Limit)
+	fired    bool
+}
+
+// crossed checks the usage reached the threshold.
+func (t *threshold) crossed(used, limit Limit) bool {
+	return float64(used) >= t.fraction*float64(limit)
+}
+
+// checkThresholdsLocked schedules callbacks of thresholds crossed for the first time in the window.
+func (l *Limiter) checkThresholdsLocked() {
+	for i := range l.thresholds {
+		t := &l.thresholds[i]
+		if t.fired || !t.crossed(l.current, l.budgetLocked()) {
+			continue
+		}
+
+		t.fired = true
+		fn, used, limit := t.fn, l.current, l.budgetLocked()
+		l.hooks = append(l.hooks, func() { fn(used, limit) })
+	}
+}
+
+// rearmThresholdsLocked arms thresholds again.
+// If all is false, only thresholds with the usage below them are armed.
+func (l *Limiter) rearmThresholdsLocked(all bool) {
+	for i := range l.thresholds {
+		t := &l.thresholds[i]
+		if all || !t.crossed(l.current, l.budgetLocked()) {
+			t.fired = false
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithThreshold(t *testing.T) {
+	clock := newFakeClock()
+
+	var fired []Limit
+	l := New(WithRate(10, time.Minute), WithClock(clock), WithThreshold(0.8, func(used, limit Limit) {
+		if limit != 10 {
+			t.Errorf("limit %d, want 10", limit)
+		}
+		fired = append(fired, used)
+	}))
+	defer l.Close()
+
+	for i := 1; i <= 7; i++ {
+		l.Allow()
+	}
+	if len(fired) != 0 {
+		t.Fatalf("fired at %v before the boundary", fired)
+	}
+	l.Allow()
+	if len(fired) != 1 || fired[0] != 8 {
+		t.Fatalf("fired at %v, want exactly at 8", fired)
+	}
+	allowed(l, 5)
+	if len(fired) != 1 {
+		t.Fatalf("fired at %v, want once per window", fired)
+	}
+
+	// The reset arms the threshold again.
+	clock.Advance(time.Minute)
+	allowed(l, 8)
+	if len(fired) != 2 || fired[1] != 8 {
+		t.Fatalf("fired at %v, want again at 8 after the reset", fired)
+	}
+}
+
+func TestWithThresholdRearm(t *testing.T) {
+	clock := newFakeClock()
+
+	fired := 0
+	l := New(WithRate(10, 10*time.Second), WithClock(clock), WithGradualRecovery(0, 0), WithThresholdRearm(),
+		WithOnExhausted(func() { fired++ }))
+	defer l.Close()
+
+	allowed(l, 10)
+	if fired != 1 {
+		t.Fatalf("fired %d times, want 1", fired)
+	}
+
+	// A recovered unit drops the usage below the limit, so consuming it fires again.
+	clock.Advance(time.Second)
+	allowed(l, 1)
+	if fired != 2 {
+		t.Fatalf("fired %d times, want 2 after the usage dropped and rose again", fired)
+	}
+}
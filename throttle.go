@@ -0,0 +1,44 @@
+package limiter
+
+import "context"
+
+// ThrottleChan forwards items from in to the returned channel,
+// waiting for a unit of the limiter before each item.
+// The returned channel is closed when in is closed, ctx is done, the limiter is closed
+// or a filter denies an item.
+// An item which was already read from in but not permitted yet when ctx is done is dropped.
+func ThrottleChan[T any](ctx context.Context, l *Limiter, in <-chan T) <-chan T {
+	l.checkNew()
+	out := make(chan T)
+	go func() {
+		defer close(out)
+
+		for {
+			var (
+				item T
+				ok   bool
+			)
+			select {
+			case item, ok = <-in:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+
+			if _, err := l.wait(ctx, "", 1); err != nil {
+				return
+			}
+
+			select {
+			case out <- item:
+			case <-ctx.Done():
+				l.refund(1)
+				return
+			}
+		}
+	}()
+
+	return out
+}
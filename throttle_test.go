@@ -0,0 +1,73 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestThrottleChan(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(2, time.Minute), WithClock(clock))
+	defer l.Close()
+
+	in := make(chan int, 5)
+	for i := 0; i < 5; i++ {
+		in <- i
+	}
+	close(in)
+	out := ThrottleChan(context.Background(), l, in)
+
+	// The items keep their order, two are forwarded per window.
+	next := 0
+	for window := 0; window < 3; window++ {
+		for i := 0; i < 2 && next < 5; i++ {
+			select {
+			case got := <-out:
+				if got != next {
+					t.Fatalf("got item %d, want %d", got, next)
+				}
+				next++
+			case <-time.After(time.Second):
+				t.Fatalf("item %d isn't forwarded in window %d", next, window)
+			}
+		}
+		if next < 5 {
+			eventually(t, func() bool { return l.Waiters() == 1 })
+			clock.Advance(time.Minute)
+		}
+	}
+
+	// The channel is closed after in is drained.
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Fatal("received an item after in was drained")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the channel isn't closed after in was closed")
+	}
+}
+
+func TestThrottleChanCancel(t *testing.T) {
+	l := New(WithRate(10, time.Minute))
+	defer l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan int, 1)
+	in <- 1
+	out := ThrottleChan(ctx, l, in)
+
+	// The item is permitted but not received, its unit is refunded on cancel.
+	eventually(t, func() bool { return l.Used() == 1 })
+	cancel()
+	eventually(t, func() bool { return l.Used() == 0 })
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Fatal("received an item after the cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the channel isn't closed after the cancel")
+	}
+}
@@ -0,0 +1,33 @@
+package limiter
+
+import "context"
+
+// Tick returns a channel which receives a value each time a unit is consumed for the caller.
+// The next unit is acquired only after the previous value was received,
+// so the consumer can't be ahead of the limiter. Several Tick channels share the limit
+// like other waiters do. The channel is closed when ctx is done, the limiter is closed
+// or a filter denies a unit.
+func (l *Limiter) Tick(ctx context.Context) <-chan struct{} {
+	l.checkNew()
+	c := make(chan struct{})
+	go func() {
+		defer close(c)
+
+		for {
+			if _, err := l.wait(ctx, "", 1); err != nil {
+				return
+			}
+
+			select {
+			case c <- struct{}{}:
+			case <-ctx.Done():
+				l.refund(1)
+				return
+			case <-l.done:
+				return
+			}
+		}
+	}()
+
+	return c
+}
@@ -0,0 +1,66 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTick(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(2, time.Minute), WithClock(clock))
+	defer l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := l.Tick(ctx)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-c:
+		case <-time.After(time.Second):
+			t.Fatalf("tick %d isn't received within the limit", i+1)
+		}
+	}
+
+	// The third unit waits for the next window.
+	eventually(t, func() bool { return l.Waiters() == 1 })
+	select {
+	case <-c:
+		t.Fatal("received a tick over the limit")
+	default:
+	}
+	clock.Advance(time.Minute)
+	select {
+	case <-c:
+	case <-time.After(time.Second):
+		t.Fatal("no tick in the next window")
+	}
+
+	// The unit acquired for the tick nobody received is refunded.
+	eventually(t, func() bool { return l.Used() == 2 })
+	cancel()
+	eventually(t, func() bool { return l.Used() == 1 })
+	select {
+	case _, ok := <-c:
+		if ok {
+			t.Fatal("received a tick after the cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the channel isn't closed after the cancel")
+	}
+}
+
+func TestTickClose(t *testing.T) {
+	l := New(WithRate(1, time.Hour))
+
+	c := l.Tick(context.Background())
+	<-c
+	l.Close()
+	select {
+	case _, ok := <-c:
+		if ok {
+			t.Fatal("received a tick after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the channel isn't closed after Close")
+	}
+}
@@ -0,0 +1,101 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+// Ticker delivers a tick each time a unit is granted, see Limiter.NewTicker.
+type Ticker struct {
+	// C receives the time a unit was granted at. Like time.Ticker, it is not closed.
+	C <-chan time.Time
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// NewTicker returns a ticker paced by the limiter. Unlike Tick, grants are spread evenly
+// across the interval instead of bursting at the window start, and the spacing follows
+// the live limit, so SetLimit changes the cadence. If the consumer is behind,
+// the tick is dropped and its unit is returned. The ticker stops when ctx is done,
+// the limiter is closed or Stop is called.
+func (l *Limiter) NewTicker(ctx context.Context) *Ticker {
+	l.checkNew()
+
+	ctx, cancel := context.WithCancel(ctx)
+	c := make(chan time.Time, 1)
+	t := &Ticker{C: c, stop: cancel, done: make(chan struct{})}
+	go t.run(ctx, l, c)
+
+	return t
+}
+
+// Stop turns off the ticker and waits until it doesn't consume units anymore.
+func (t *Ticker) Stop() {
+	t.stop()
+	<-t.done
+}
+
+func (t *Ticker) run(ctx context.Context, l *Limiter, c chan<- time.Time) {
+	defer close(t.done)
+
+	next := l.now()
+	for {
+		fire, release := l.after(next.Sub(l.now()))
+		select {
+		case <-fire:
+		case <-ctx.Done():
+			release()
+			return
+		}
+
+		if _, err := l.wait(ctx, "", 1); err != nil {
+			return
+		}
+
+		now, spacing := l.now(), l.spacing()
+		if spacing == 0 {
+			// Nothing to pace, the consumer sets the cadence.
+			select {
+			case c <- now:
+			case <-ctx.Done():
+				l.refund(1)
+				return
+			}
+		} else {
+			select {
+			case c <- now:
+			default:
+				l.refund(1)
+			}
+		}
+
+		next = now.Add(spacing)
+	}
+}
+
+// spacing returns the time between evenly spread units of the budget.
+func (l *Limiter) spacing() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.spacingLocked()
+}
+
+// spacingLocked is spacing under the lock.
+func (l *Limiter) spacingLocked() time.Duration {
+	budget := l.budgetLocked()
+	if l.refill != 0 {
+		budget = l.refill
+	}
+	switch {
+	case budget == Infinite:
+		return 0
+	case budget == 0:
+		return l.interval
+	case budget > Limit(l.interval):
+		return 1
+	}
+
+	return l.interval / time.Duration(budget)
+}
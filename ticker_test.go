@@ -0,0 +1,64 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTicker(t *testing.T) {
+	clock := newFakeClock()
+	// The lazy limiter has no timers of its own, the pending one is the ticker's.
+	l := New(WithRate(4, time.Minute), WithClock(clock), WithLazyReset())
+	start := clock.Now()
+
+	ticker := l.NewTicker(context.Background())
+	tick := func() time.Time {
+		t.Helper()
+		select {
+		case at := <-ticker.C:
+			return at
+		case <-time.After(time.Second):
+			t.Fatal("no tick")
+		}
+		return time.Time{}
+	}
+	pending := func() bool {
+		_, n := clock.wakeups()
+		return n == 1
+	}
+
+	// The units are spread across the interval instead of bursting.
+	if at := tick(); !at.Equal(start) {
+		t.Fatalf("first tick at %v, want at once", at.Sub(start))
+	}
+	for i := 1; i < 4; i++ {
+		eventually(t, pending)
+		clock.Advance(10 * time.Second)
+		select {
+		case <-ticker.C:
+			t.Fatalf("tick %d before the spacing", i+1)
+		case <-time.After(10 * time.Millisecond):
+		}
+		clock.Advance(5 * time.Second)
+		if at := tick(); at.Sub(start) != time.Duration(i)*15*time.Second {
+			t.Fatalf("tick %d at %v, want %v", i+1, at.Sub(start), time.Duration(i)*15*time.Second)
+		}
+	}
+
+	// Nothing is consumed after Stop.
+	eventually(t, pending)
+	ticker.Stop()
+	if got := l.Used(); got != 4 {
+		t.Fatalf("used %d at Stop, want 4", got)
+	}
+	clock.Advance(time.Minute)
+	select {
+	case <-ticker.C:
+		t.Fatal("tick after Stop")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if got := l.Used(); got != 0 {
+		t.Fatalf("used %d in the window after Stop, want 0", got)
+	}
+}
@@ -0,0 +1,89 @@
+package limiter
+
+import "time"
+
+// Token is a unit taken by TryAcquire, or units taken by TryAcquireN, which must be committed or rolled back.
+// A token not resolved before the window it was taken in resets is treated as committed:
+// Rollback after the reset doesn't return the unit.
+type Token struct {
+	t *token
+}
+
+type token struct {
+	l        *Limiter
+	n        Limit
+	window   time.Time
+	resolved bool
+}
+
+// TryAcquire takes a unit if it is available without the reserved units, see Allow.
+// The unit is counted in the usage, but not in Stats until the token is committed.
+// The deny policy is not applied.
+func (l *Limiter) TryAcquire() (Token, bool) {
+	return l.tryAcquire("TryAcquire", 1)
+}
+
+// TryAcquireN is the same as TryAcquire, but takes n units at once or nothing.
+func (l *Limiter) TryAcquireN(n Limit) (Token, bool) {
+	return l.tryAcquire("TryAcquireN", n)
+}
+
+func (l *Limiter) tryAcquire(method string, n Limit) (Token, bool) {
+	l.checkNew()
+	if l.filtered() {
+		return Token{}, false
+	}
+
+	l.mu.Lock()
+	l.checkStrictLocked(method)
+	defer l.unlock()
+
+	now := l.now()
+	l.advanceLocked(now)
+	if !l.availableLocked(n, l.reserved) {
+		l.denyLocked(n)
+		return Token{}, false
+	}
+
+	l.holdLocked(n, now)
+
+	return Token{t: &token{l: l, n: n, window: l.windowStart}}, true
+}
+
+// Commit finalizes the units.
+// A resolved token is ignored, in strict mode it panics.
+func (t Token) Commit() {
+	t.resolve("Commit", false)
+}
+
+// Rollback returns the units to the limiter without counting it in Stats.
+// A resolved token is ignored, in strict mode it panics.
+func (t Token) Rollback() {
+	t.resolve("Rollback", true)
+}
+
+func (t Token) resolve(method string, rollback bool) {
+	if t.t == nil {
+		return
+	}
+
+	l := t.t.l
+	now := l.lock()
+	if t.t.resolved {
+		strict := l.strict
+		l.unlock()
+		if strict {
+			panic("limiter: Token." + method + " called on a resolved token")
+		}
+		return
+	}
+	t.t.resolved = true
+	defer l.unlock()
+
+	if rollback && l.windowStart.Equal(t.t.window) {
+		l.releaseLocked(t.t.n)
+		return
+	}
+
+	l.countLocked(t.t.n, now)
+}
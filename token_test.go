@@ -0,0 +1,55 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTryAcquire(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(2, time.Minute), WithClock(clock))
+	defer l.Close()
+
+	committed, ok := l.TryAcquire()
+	if !ok {
+		t.Fatal("TryAcquire failed on a fresh limiter")
+	}
+	rolledBack, _ := l.TryAcquire()
+	if _, ok := l.TryAcquire(); ok {
+		t.Fatal("TryAcquire took a unit above the limit")
+	}
+	if s := l.Stats(); s.Allowed != 0 || l.Used() != 2 {
+		t.Fatalf("allowed %d and used %d with unresolved tokens, want 0 and 2", s.Allowed, l.Used())
+	}
+
+	committed.Commit()
+	rolledBack.Rollback()
+	if s := l.Stats(); s.Allowed != 1 || l.Used() != 1 {
+		t.Fatalf("allowed %d and used %d after resolving, want 1 and 1", s.Allowed, l.Used())
+	}
+
+	// Resolving again is ignored outside strict mode.
+	committed.Rollback()
+	rolledBack.Commit()
+	if s := l.Stats(); s.Allowed != 1 || l.Used() != 1 {
+		t.Fatalf("allowed %d and used %d after resolving twice, want 1 and 1", s.Allowed, l.Used())
+	}
+}
+
+func TestTryAcquireLeakedAcrossReset(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(1, time.Minute), WithClock(clock))
+	defer l.Close()
+
+	leaked, _ := l.TryAcquire()
+	clock.Advance(time.Minute)
+
+	// The token belongs to the previous window, rolling it back doesn't add a unit.
+	leaked.Rollback()
+	if got := allowed(l, 2); got != 1 {
+		t.Fatalf("allowed %d after rolling back a leaked token, want 1", got)
+	}
+	if got := l.Stats().Allowed; got != 2 {
+		t.Fatalf("allowed %d in stats, want the leaked unit counted as committed", got)
+	}
+}
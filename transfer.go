@@ -0,0 +1,110 @@
+package limiter
+
+import "time"
+
+// TransferTo moves the usage, the window phase and the blocked waiters to dst,
+// after that the limiter behaves as closed and grants nothing. Use it to apply new options without losing
+// the current window: the limit and the interval of dst are applied to the inherited usage
+// the same way as SetLimit does. Waiters keep their order and are served by dst.
+// The window phase isn't moved if dst uses a shared schedule.
+// It returns ErrClosed if either limiter is closed. It panics if dst is the limiter itself.
+func (l *Limiter) TransferTo(dst *Limiter) error {
+	l.checkNew()
+	dst.checkNew()
+	if dst == l {
+		panic("limiter: TransferTo the limiter itself")
+	}
+
+	// The limiters are locked in the order of creation like AllowAll does,
+	// so opposite concurrent transfers can't deadlock.
+	first, second := l, dst
+	if dst.id < l.id {
+		first, second = dst, l
+	}
+	now := first.lock()
+	second.mu.Lock()
+	second.advanceLocked(now)
+	if l.closed || dst.closed {
+		dst.unlock()
+		l.unlock()
+		return ErrClosed
+	}
+
+	dst.inheritLocked(l)
+	for w := l.waiters.pop(); w != nil; w = l.waiters.pop() {
+		dst.waiters.push(w)
+	}
+	l.current, l.carried, l.previous = 0, 0, 0
+	l.movedTo = dst
+	l.closed = true
+	dst.advanceLocked(now)
+	// The hooks of dst may use l, they run after both locks are released.
+	hooks := dst.unlockHooks()
+	l.unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+
+	dst.wakeCleanup()
+	l.stop()
+
+	return nil
+}
+
+// inheritLocked takes the usage and the window phase of src, both locks are held.
+func (l *Limiter) inheritLocked(src *Limiter) {
+	if l.group == nil {
+		l.windowStart = src.windowStart
+		l.restartRecoveryLocked(l.windowStart)
+		if src.gradualRecovery && l.gradualRecovery && src.interval == l.interval && src.limit == l.limit &&
+			src.recoveryStep == l.recoveryStep && src.recoveryEvery == l.recoveryEvery && src.refill == l.refill {
+			l.recoveryOrigin, l.recovered, l.nextStep = src.recoveryOrigin, src.recovered, src.nextStep
+		}
+	}
+
+	l.current, l.carried, l.previous = src.current, src.carried, src.previous
+	if l.carried > l.maxCarried {
+		l.carried = l.maxCarried
+	}
+
+	if l.log != nil {
+		l.log = newSlidingLog(len(l.log.entries))
+		entries := make([]time.Time, 0, len(l.log.entries))
+		if src.log != nil {
+			// Only the newest entries fit into the log.
+			skip := src.log.size - len(l.log.entries)
+			if skip < 0 {
+				skip = 0
+			}
+			for i := skip; i < src.log.size; i++ {
+				entries = append(entries, src.log.entries[(src.log.head+i)%len(src.log.entries)])
+			}
+		} else {
+			for i := Limit(0); i < src.current && i < Limit(len(l.log.entries)); i++ {
+				entries = append(entries, src.windowStart)
+			}
+		}
+		for _, t := range entries {
+			l.log.push(t)
+		}
+		l.current = Limit(l.log.size)
+	}
+
+	if l.overage == OverageForgive && l.current > l.limit {
+		l.current = l.limit
+	}
+}
+
+// lockOwner locks and returns the limiter which holds the waiters of l now,
+// it is l itself unless the state was transferred, see TransferTo.
+func (l *Limiter) lockOwner() *Limiter {
+	l.mu.Lock()
+	for l.movedTo != nil {
+		next := l.movedTo
+		l.mu.Unlock()
+		l = next
+		l.mu.Lock()
+	}
+
+	return l
+}
@@ -0,0 +1,151 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransferToConcurrent(t *testing.T) {
+	clock := newFakeClock()
+	src := New(WithRate(100, time.Minute), WithClock(clock))
+	dst := New(WithRate(100, time.Minute), WithClock(clock))
+	defer dst.Close()
+
+	var (
+		cur     atomic.Pointer[Limiter]
+		stop    atomic.Bool
+		granted atomic.Int64
+		wg      sync.WaitGroup
+	)
+	cur.Store(src)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !stop.Load() {
+				if cur.Load().Allow() {
+					granted.Add(1)
+				}
+			}
+		}()
+	}
+
+	eventually(t, func() bool { return granted.Load() >= 10 })
+	if err := src.TransferTo(dst); err != nil {
+		t.Fatalf("TransferTo returned %v", err)
+	}
+	cur.Store(dst)
+	eventually(t, func() bool { return dst.Remaining() == 0 })
+	stop.Store(true)
+	wg.Wait()
+
+	if got := granted.Load(); got != 100 {
+		t.Fatalf("granted %d across the transfer, want 100", got)
+	}
+	if src.Allow() {
+		t.Fatal("the source allowed after the transfer")
+	}
+}
+
+func TestTransferToWaiters(t *testing.T) {
+	clock := newFakeClock()
+	src := New(WithRate(1, time.Minute), WithClock(clock))
+	dst := New(WithRate(5, time.Minute), WithClock(clock))
+	defer dst.Close()
+
+	src.Allow()
+	done := make(chan error)
+	for i := 0; i < 3; i++ {
+		go func() { done <- src.WaitErr(context.Background()) }()
+	}
+	eventually(t, func() bool { return src.Waiters() == 3 })
+
+	// The inherited unit leaves 4 of the larger limit to the waiters.
+	if err := src.TransferTo(dst); err != nil {
+		t.Fatalf("TransferTo returned %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-done; err != nil {
+			t.Fatalf("transferred waiter got %v", err)
+		}
+	}
+	if got := dst.Remaining(); got != 1 {
+		t.Fatalf("remaining %d, want 1", got)
+	}
+}
+
+func TestTransferToOpposite(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		a, b := New(WithRate(10, time.Minute)), New(WithRate(10, time.Minute))
+
+		errs := make(chan error, 2)
+		go func() { errs <- a.TransferTo(b) }()
+		go func() { errs <- b.TransferTo(a) }()
+
+		first, second := <-errs, <-errs
+		if (first == nil) == (second == nil) || !errors.Is(first, ErrClosed) && !errors.Is(second, ErrClosed) {
+			t.Fatalf("opposite transfers returned %v and %v, want one ErrClosed", first, second)
+		}
+		a.Close()
+		b.Close()
+	}
+}
+
+func TestTransferToSlidingLog(t *testing.T) {
+	clock := newFakeClock()
+	src := New(WithRate(10, time.Minute), WithSlidingLog(10), WithClock(clock))
+	dst := New(WithRate(4, time.Minute), WithSlidingLog(10), WithClock(clock))
+	defer dst.Close()
+
+	for i := 0; i < 10; i++ {
+		src.Allow()
+		clock.Advance(time.Second)
+	}
+	if err := src.TransferTo(dst); err != nil {
+		t.Fatalf("TransferTo returned %v", err)
+	}
+	if got := dst.Current(); got != 4 {
+		t.Fatalf("current %d, want the limit 4", got)
+	}
+
+	// Only the newest entries are kept, the oldest ones expiring frees nothing.
+	clock.Advance(time.Minute - 5*time.Second)
+	if dst.Allow() {
+		t.Fatal("allowed when the oldest source entries expire")
+	}
+	clock.Advance(time.Second)
+	if !dst.Allow() {
+		t.Fatal("not allowed when the oldest inherited entry expires")
+	}
+}
+
+func TestTransferToHookUsesSource(t *testing.T) {
+	clock := newFakeClock()
+	src := New(WithRate(1, time.Minute), WithClock(clock))
+	hooked := make(chan bool, 2)
+	// The shorter interval ends the inherited window at once and runs the reset hook.
+	dst := New(WithRate(1, 30*time.Second), WithClock(clock), WithOnReset(func() {
+		hooked <- src.Allow()
+	}))
+	defer dst.Close()
+
+	src.Allow()
+	clock.Advance(40 * time.Second)
+	done := make(chan error)
+	go func() { done <- src.TransferTo(dst) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("TransferTo returned %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("TransferTo is blocked on a hook of the destination")
+	}
+	if <-hooked {
+		t.Fatal("the source allowed after the transfer")
+	}
+}
@@ -0,0 +1,107 @@
+package limiter
+
+import (
+	"math"
+	"time"
+)
+
+// warmupStart is the part of the limit allowed when the warm-up begins.
+const warmupStart = 0.1
+
+// limitLocked returns the limit, lowered while the limiter warms up, see WithWarmup.
+func (l *Limiter) limitLocked() Limit {
+	if !l.warmingLocked() {
+		return l.limit
+	}
+
+	part := warmupStart
+	if elapsed := l.lastNow.Sub(l.warmStart); elapsed > 0 {
+		part += (1 - warmupStart) * float64(elapsed) / float64(l.warmup)
+	}
+
+	limit := Limit(float64(l.limit) * part)
+	switch {
+	case limit < 1:
+		return 1
+	case limit > l.limit:
+		return l.limit
+	}
+
+	return limit
+}
+
+// warmingLocked reports whether the warm-up period is not over.
+func (l *Limiter) warmingLocked() bool {
+	return l.warmup > 0 && !l.external && l.limit != 0 && l.limit != Infinite && l.lastNow.Sub(l.warmStart) < l.warmup
+}
+
+// warmupSteps is how many times at most the warm-up limit is updated in the background.
+const warmupSteps = 100
+
+// warmupEventLocked returns time when the warm-up limit should be updated,
+// the zero time if it doesn't grow anymore. A large limit grows by many units
+// a millisecond, so the limit is updated in warmupSteps steps of the warm-up,
+// or when the first waiter fits if that is earlier.
+func (l *Limiter) warmupEventLocked() time.Time {
+	if !l.warmingLocked() {
+		return time.Time{}
+	}
+
+	step := l.warmup / warmupSteps
+	if step <= 0 {
+		step = 1
+	}
+	at := l.warmStart.Add((l.lastNow.Sub(l.warmStart)/step + 1) * step)
+	if unit := l.warmupReachLocked(l.limitLocked() + 1); unit.After(at) {
+		at = unit
+	}
+	if end := l.warmStart.Add(l.warmup); at.After(end) {
+		at = end
+	}
+
+	if w := l.waiters.peek(); w != nil {
+		if fits, ok := l.warmupAvailableAtLocked(w.n, l.reserved); ok && fits.After(l.lastNow) && fits.Before(at) {
+			at = fits
+		}
+	}
+
+	return at
+}
+
+// warmupReachLocked returns time when the warm-up limit grows to limit.
+func (l *Limiter) warmupReachLocked(limit Limit) time.Time {
+	part := float64(limit) / float64(l.limit)
+	offset := time.Duration(math.Ceil((part - warmupStart) / (1 - warmupStart) * float64(l.warmup)))
+	switch {
+	case offset < 0:
+		offset = 0
+	case offset > l.warmup:
+		offset = l.warmup
+	}
+
+	return l.warmStart.Add(offset)
+}
+
+// warmupAvailableAtLocked returns time when the warm-up limit grows enough for n units
+// without the reserved units, false if they don't fit into the full limit either.
+func (l *Limiter) warmupAvailableAtLocked(n, reserved Limit) (time.Time, bool) {
+	fits := func(limit Limit) bool {
+		room, ok := l.roomLocked(addLimit(limit, l.carried), n, reserved)
+		return ok && l.usageLocked() <= room
+	}
+
+	low, high := l.limitLocked(), l.limit
+	if !fits(high) {
+		return time.Time{}, false
+	}
+	for low < high {
+		mid := low + (high-low)/2
+		if fits(mid) {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+
+	return l.warmupReachLocked(high), true
+}
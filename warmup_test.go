@@ -0,0 +1,82 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithWarmup(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(1000, time.Hour), WithWarmup(time.Minute), WithClock(clock))
+	defer l.Close()
+
+	// The warm-up starts at a tenth of the limit and grows by 15 units a second.
+	if got := allowed(l, 101); got != 100 {
+		t.Fatalf("allowed %d at the start of the warm-up, want 100", got)
+	}
+	if got, want := l.RetryAfter(), 66667*time.Microsecond; got < want-time.Millisecond || got > want+time.Millisecond {
+		t.Fatalf("retry after %v, want about %v", got, want)
+	}
+	clock.Advance(l.RetryAfter())
+	if !l.Allow() {
+		t.Fatal("not allowed when the warm-up limit grew")
+	}
+
+	// More than the warm-up limit isn't more than the limit, the wait lasts until it grows enough.
+	done := make(chan bool)
+	go func() { done <- l.WaitN(context.Background(), 500) }()
+	eventually(t, func() bool {
+		select {
+		case ok := <-done:
+			t.Fatalf("WaitN returned %t before the warm-up limit grew", ok)
+		default:
+		}
+		return l.Waiters() == 1
+	})
+	clock.Advance(33 * time.Second)
+	eventually(t, func() bool { return l.Waiters() == 1 })
+	clock.Advance(time.Second)
+	if !<-done {
+		t.Fatal("WaitN failed when the warm-up limit grew enough")
+	}
+	if got := l.Used(); got != 601 {
+		t.Fatalf("used %d, want 601", got)
+	}
+}
+
+func TestWarmupEvents(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(1000000, time.Hour), WithWarmup(time.Minute), WithClock(clock))
+	defer l.Close()
+
+	// The limit grows by 15000 units a second, it is updated every 600ms instead.
+	clock.Advance(time.Second)
+	now := l.lock()
+	next := l.warmupEventLocked()
+	l.unlock()
+	if want := now.Add(200 * time.Millisecond); !next.Equal(want) {
+		t.Fatalf("warm-up event at %v, want %v", next, want)
+	}
+
+	// A waiter is woken up when it fits, not at the next step.
+	allowed(l, 115000)
+	go l.WaitN(context.Background(), 1000)
+	eventually(t, func() bool { return l.Waiters() == 1 })
+	now = l.lock()
+	next = l.warmupEventLocked()
+	fits, _ := l.warmupAvailableAtLocked(1000, 0)
+	l.unlock()
+	if !next.Equal(fits) || !next.After(now) || next.Sub(now) >= 100*time.Millisecond {
+		t.Fatalf("warm-up event at %v with a waiter, want %v", next, fits)
+	}
+
+	clock.Advance(time.Minute)
+	eventually(t, func() bool { return l.Waiters() == 0 })
+	l.lock()
+	next = l.warmupEventLocked()
+	l.unlock()
+	if !next.IsZero() {
+		t.Fatalf("warm-up event at %v after the warm-up, want none", next)
+	}
+}
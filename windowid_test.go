@@ -0,0 +1,79 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowID(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "eager"},
+		{name: "lazy", opts: []Option{WithLazyReset()}},
+		{name: "gradual", opts: []Option{WithGradualRecovery(1, 10*time.Second)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			l := New(append([]Option{WithRate(6, time.Minute), WithClock(clock)}, tt.opts...)...)
+			defer l.Close()
+
+			assert := func(want uint64) {
+				t.Helper()
+				if got := l.WindowID(); got != want {
+					t.Fatalf("window %d, want %d", got, want)
+				}
+				if got := l.State().WindowID; got != want {
+					t.Fatalf("State window %d, want %d", got, want)
+				}
+			}
+
+			assert(0)
+			// Recovery steps and SetLimit stay in the window.
+			allowed(l, 6)
+			clock.Advance(30 * time.Second)
+			l.SetLimit(3)
+			assert(0)
+			clock.Advance(30 * time.Second)
+			assert(1)
+			// The windows passed without access are counted.
+			clock.Advance(3*time.Minute + 59*time.Second)
+			assert(4)
+			clock.Advance(time.Second)
+			assert(5)
+
+			restored := New(WithRate(6, time.Minute), WithClock(clock), WithState(l.State()))
+			defer restored.Close()
+			if got := restored.WindowID(); got != 5 {
+				t.Fatalf("restored window %d, want 5", got)
+			}
+		})
+	}
+}
+
+func TestWindowIDSetInterval(t *testing.T) {
+	clock := newFakeClock()
+	l := New(WithRate(6, time.Minute), WithClock(clock))
+	defer l.Close()
+
+	clock.Advance(30 * time.Second)
+	// The window still runs with a longer interval.
+	l.SetInterval(2 * time.Minute)
+	if got := l.WindowID(); got != 0 {
+		t.Fatalf("window %d after a longer interval, want 0", got)
+	}
+
+	// A shorter interval ends the window at once and counts the intervals passed in it.
+	clock.Advance(5 * time.Second)
+	l.SetInterval(10 * time.Second)
+	if got := l.WindowID(); got != 3 {
+		t.Fatalf("window %d after a shorter interval, want 3", got)
+	}
+	clock.Advance(5 * time.Second)
+	if got := l.WindowID(); got != 4 {
+		t.Fatalf("window %d after the new interval, want 4", got)
+	}
+}
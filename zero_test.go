@@ -0,0 +1,55 @@
+package limiter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestZeroValuePanics(t *testing.T) {
+	var l Limiter
+
+	v := reflect.ValueOf(&l)
+	for i := 0; i < v.NumMethod(); i++ {
+		m := v.Type().Method(i)
+		t.Run(m.Name, func(t *testing.T) {
+			args := make([]reflect.Value, m.Type.NumIn()-1)
+			for j := range args {
+				args[j] = reflect.Zero(m.Type.In(j + 1))
+			}
+
+			defer func() {
+				if r := recover(); r != "limiter: Limiter must be created with New" {
+					t.Fatalf("%s of the zero value panicked with %v", m.Name, r)
+				}
+			}()
+			v.Method(i).Call(args)
+		})
+	}
+}
+
+func TestZeroValuePanicsFuncs(t *testing.T) {
+	var l Limiter
+	other := New()
+	defer other.Close()
+
+	tests := []struct {
+		name string
+		call func()
+	}{
+		{name: "NewGroup", call: func() { NewGroup(context.Background(), &l) }},
+		{name: "AllowAll", call: func() { AllowAll(other, &l) }},
+		{name: "TransferTo", call: func() { other.TransferTo(&l) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r != "limiter: Limiter must be created with New" {
+					t.Fatalf("panicked with %v", r)
+				}
+			}()
+			tt.call()
+		})
+	}
+}